@@ -0,0 +1,379 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// filter of a subscription, e.g. `msg > 40 && sector != "A1"` or `msg.flag == true || !(msg.level < 3)`.
+// Operands are fields of the message, numbers, 'strings' or "strings", true and false; comparisons
+// are ==, !=, <, <=, > and >=, joined by &&, || and ! with parentheses. A field alone is true if it is
+// true, a non zero number or a non empty string. A comparison with a missing field is false
+type contentFilter interface {
+	eval(fields map[string]interface{}) interface{}
+}
+
+// compiled filters, by expression
+var contentFilters sync.Map
+
+// returns compiled filter of expression, compiling it only the first time
+func compileFilter(expression string) (contentFilter, error) {
+	if filter, found := contentFilters.Load(expression); found {
+		return filter.(contentFilter), nil
+	}
+
+	tokens, err := tokenizeFilter(expression)
+	if err != nil {
+		return nil, err
+	}
+	parser := &filterParser{tokens: tokens}
+	filter, err := parser.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if parser.position < len(parser.tokens) {
+		return nil, errors.New("filter " + expression + " : unexpected " + parser.tokens[parser.position].text)
+	}
+
+	contentFilters.Store(expression, filter)
+	return filter, nil
+}
+
+// tells if message with fields passes filter expression, an empty expression lets every message pass
+func filterMatches(expression string, fields map[string]interface{}) bool {
+	if expression == "" {
+		return true
+	}
+	filter, err := compileFilter(expression)
+	if err != nil {
+		// filters are validated when subscriptions are made
+		return false
+	}
+	return truthy(filter.eval(fields))
+}
+
+// fields of sensor message a filter can use: msg, sensor, sector, topic and payload. A msg which is a json
+// object has its fields below msg, e.g. msg.flag, payload has value, unit, timestamp and attributes
+// below it, e.g. payload.value or payload.attributes.battery
+func filterFields(sensor Sensor) map[string]interface{} {
+	fields := map[string]interface{}{
+		"msg":    sensor.Message,
+		"sensor": sensor.Id,
+		"sector": sensor.CurrentSector,
+		"topic":  sensor.Type,
+	}
+
+	if strings.HasPrefix(strings.TrimSpace(sensor.Message), "{") {
+		var object map[string]interface{}
+		if json.Unmarshal([]byte(sensor.Message), &object) == nil {
+			fields["msg"] = object
+		}
+	}
+
+	// payload goes through json, so that its numbers are compared as every other number
+	if sensor.Payload != nil {
+		if data, err := json.Marshal(sensor.Payload); err == nil {
+			var object map[string]interface{}
+			if json.Unmarshal(data, &object) == nil {
+				fields["payload"] = object
+			}
+		}
+	}
+	return fields
+}
+
+var filterOperators = map[string]bool{
+	"==": true, "!=": true, "<": true, "<=": true, ">": true, ">=": true, "&&": true, "||": true, "!": true,
+}
+
+type filterToken struct {
+	kind string // "field", "number", "string", "bool", "op", "(" or ")"
+	text string
+}
+
+func tokenizeFilter(expression string) ([]filterToken, error) {
+	tokens := []filterToken{}
+	for position := 0; position < len(expression); {
+		char := expression[position]
+		switch {
+		case char == ' ' || char == '\t':
+			position++
+
+		case char == '(' || char == ')':
+			tokens = append(tokens, filterToken{kind: string(char), text: string(char)})
+			position++
+
+		case strings.ContainsRune("=!<>&|", rune(char)):
+			op := string(char)
+			if position+1 < len(expression) && filterOperators[op+string(expression[position+1])] {
+				op += string(expression[position+1])
+			}
+			if !filterOperators[op] {
+				return nil, errors.New("filter " + expression + " : unknown operator " + op)
+			}
+			tokens = append(tokens, filterToken{kind: "op", text: op})
+			position += len(op)
+
+		case char == '"' || char == '\'':
+			end := strings.IndexByte(expression[position+1:], char)
+			if end < 0 {
+				return nil, errors.New("filter " + expression + " : unterminated string")
+			}
+			tokens = append(tokens, filterToken{kind: "string", text: expression[position+1 : position+1+end]})
+			position += end + 2
+
+		default:
+			end := position
+			for end < len(expression) && !strings.ContainsRune(" \t()=!<>&|\"'", rune(expression[end])) {
+				end++
+			}
+			word := expression[position:end]
+			switch {
+			case word == "true" || word == "false":
+				tokens = append(tokens, filterToken{kind: "bool", text: word})
+			case isFilterNumber(word):
+				tokens = append(tokens, filterToken{kind: "number", text: word})
+			default:
+				tokens = append(tokens, filterToken{kind: "field", text: word})
+			}
+			position = end
+		}
+	}
+	return tokens, nil
+}
+
+func isFilterNumber(word string) bool {
+	_, err := strconv.ParseFloat(word, 64)
+	return err == nil
+}
+
+// recursive descent parser of filter expressions
+type filterParser struct {
+	tokens   []filterToken
+	position int
+}
+
+func (parser *filterParser) peek() (filterToken, bool) {
+	if parser.position < len(parser.tokens) {
+		return parser.tokens[parser.position], true
+	}
+	return filterToken{}, false
+}
+
+func (parser *filterParser) accept(kind string, text string) bool {
+	if token, ok := parser.peek(); ok && token.kind == kind && token.text == text {
+		parser.position++
+		return true
+	}
+	return false
+}
+
+func (parser *filterParser) parseOr() (contentFilter, error) {
+	left, err := parser.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for parser.accept("op", "||") {
+		right, err := parser.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalFilter{op: "||", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (parser *filterParser) parseAnd() (contentFilter, error) {
+	left, err := parser.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for parser.accept("op", "&&") {
+		right, err := parser.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalFilter{op: "&&", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (parser *filterParser) parseUnary() (contentFilter, error) {
+	if parser.accept("op", "!") {
+		operand, err := parser.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notFilter{operand: operand}, nil
+	}
+	if parser.accept("(", "(") {
+		inner, err := parser.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !parser.accept(")", ")") {
+			return nil, errors.New("filter : missing )")
+		}
+		return inner, nil
+	}
+
+	left, err := parser.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	token, ok := parser.peek()
+	if !ok || token.kind != "op" || token.text == "&&" || token.text == "||" || token.text == "!" {
+		return left, nil
+	}
+	parser.position++
+	right, err := parser.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	return comparisonFilter{op: token.text, left: left, right: right}, nil
+}
+
+func (parser *filterParser) parseOperand() (contentFilter, error) {
+	token, ok := parser.peek()
+	if !ok {
+		return nil, errors.New("filter : unexpected end")
+	}
+	parser.position++
+
+	switch token.kind {
+	case "field":
+		return fieldFilter{path: strings.Split(token.text, ".")}, nil
+	case "number":
+		number, _ := strconv.ParseFloat(token.text, 64)
+		return literalFilter{value: number}, nil
+	case "string":
+		return literalFilter{value: token.text}, nil
+	case "bool":
+		return literalFilter{value: token.text == "true"}, nil
+	}
+	return nil, errors.New("filter : unexpected " + token.text)
+}
+
+type literalFilter struct {
+	value interface{}
+}
+
+func (filter literalFilter) eval(fields map[string]interface{}) interface{} {
+	return filter.value
+}
+
+// value of a field, nil if message has not got it
+type fieldFilter struct {
+	path []string
+}
+
+func (filter fieldFilter) eval(fields map[string]interface{}) interface{} {
+	var value interface{} = fields
+	for _, name := range filter.path {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = object[name]
+	}
+	return value
+}
+
+type notFilter struct {
+	operand contentFilter
+}
+
+func (filter notFilter) eval(fields map[string]interface{}) interface{} {
+	return !truthy(filter.operand.eval(fields))
+}
+
+type logicalFilter struct {
+	op          string
+	left, right contentFilter
+}
+
+func (filter logicalFilter) eval(fields map[string]interface{}) interface{} {
+	if filter.op == "&&" {
+		return truthy(filter.left.eval(fields)) && truthy(filter.right.eval(fields))
+	}
+	return truthy(filter.left.eval(fields)) || truthy(filter.right.eval(fields))
+}
+
+type comparisonFilter struct {
+	op          string
+	left, right contentFilter
+}
+
+func (filter comparisonFilter) eval(fields map[string]interface{}) interface{} {
+	left := filter.left.eval(fields)
+	right := filter.right.eval(fields)
+	if left == nil || right == nil {
+		return false
+	}
+
+	// numbers sent as text, like most sensor messages, are compared as numbers
+	leftNumber, leftIsNumber := filterNumber(left)
+	rightNumber, rightIsNumber := filterNumber(right)
+	if leftIsNumber && rightIsNumber {
+		return compare(filter.op, leftNumber < rightNumber, leftNumber == rightNumber)
+	}
+
+	leftText, leftIsText := left.(string)
+	rightText, rightIsText := right.(string)
+	if leftIsText && rightIsText {
+		return compare(filter.op, leftText < rightText, leftText == rightText)
+	}
+
+	leftBool, leftIsBool := left.(bool)
+	rightBool, rightIsBool := right.(bool)
+	if leftIsBool && rightIsBool && (filter.op == "==" || filter.op == "!=") {
+		return (leftBool == rightBool) == (filter.op == "==")
+	}
+	return false
+}
+
+func compare(op string, less bool, equal bool) bool {
+	switch op {
+	case "==":
+		return equal
+	case "!=":
+		return !equal
+	case "<":
+		return less
+	case "<=":
+		return less || equal
+	case ">":
+		return !less && !equal
+	case ">=":
+		return !less
+	}
+	return false
+}
+
+func filterNumber(value interface{}) (float64, bool) {
+	switch value := value.(type) {
+	case float64:
+		return value, true
+	case string:
+		number, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		return number, err == nil
+	}
+	return 0, false
+}
+
+func truthy(value interface{}) bool {
+	switch value := value.(type) {
+	case bool:
+		return value
+	case float64:
+		return value != 0
+	case string:
+		return value != ""
+	case nil:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,79 @@
+package main
+
+import "testing"
+
+func TestFilterMatches(t *testing.T) {
+	sensor := Sensor{
+		Id:            "s1",
+		Message:       `{"flag": true, "level": 2, "name": "probe"}`,
+		CurrentSector: "A1",
+		Type:          "temperature",
+		Payload:       &SensorPayload{Value: 41.5, Unit: "C", Attributes: map[string]interface{}{"battery": 80}},
+	}
+	fields := filterFields(sensor)
+
+	for _, test := range []struct {
+		expression string
+		want       bool
+	}{
+		{``, true},
+		{`sector == "A1"`, true},
+		{`sector != 'A1'`, false},
+		{`topic == "temperature" && sensor == "s1"`, true},
+		{`msg.flag == true`, true},
+		{`msg.flag`, true},
+		{`msg.level < 3`, true},
+		{`msg.level >= 3`, false},
+		{`!(msg.level < 3)`, false},
+		{`msg.name == "probe" || msg.level > 10`, true},
+		{`msg.missing == 1`, false},
+		{`msg.missing != 1`, false},
+		{`payload.value > 40 && payload.unit == 'C'`, true},
+		{`payload.attributes.battery <= 50`, false},
+		{`(sector == "B" || sector == "A1") && !msg.missing`, true},
+	} {
+		if got := filterMatches(test.expression, fields); got != test.want {
+			t.Errorf("filter %q is %v, want %v", test.expression, got, test.want)
+		}
+	}
+}
+
+func TestFilterNumericMessage(t *testing.T) {
+	fields := filterFields(Sensor{Id: "s1", Message: "23.5"})
+
+	if !filterMatches("msg > 20", fields) {
+		t.Error("message made of a number is not compared as a number")
+	}
+	if filterMatches("msg == 'hot'", fields) {
+		t.Error("numeric message equals a string")
+	}
+}
+
+func TestCompileFilterErrors(t *testing.T) {
+	for _, expression := range []string{
+		`msg >`,
+		`msg > 40 &&`,
+		`(msg > 40`,
+		`msg > 40)`,
+		`msg = 40`,
+		`msg > "unterminated`,
+		`msg 40`,
+	} {
+		if _, err := compileFilter(expression); err == nil {
+			t.Errorf("filter %q compiled", expression)
+		}
+		if _, found := contentFilters.Load(expression); found {
+			t.Errorf("filter %q was cached though it does not compile", expression)
+		}
+	}
+}
+
+func TestCompileFilterCaches(t *testing.T) {
+	expression := `sector == "A2" && msg > 1`
+	if _, err := compileFilter(expression); err != nil {
+		t.Fatal(err)
+	}
+	if _, found := contentFilters.Load(expression); !found {
+		t.Errorf("filter %q was not cached", expression)
+	}
+}
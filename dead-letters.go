@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"github.com/lithammer/shortuuid"
+	"time"
+)
+
+// DeadLetter is a message which could not be delivered to a bot within its retry policy
+type DeadLetter struct {
+	Id        string    `json:"id"`
+	BotId     string    `json:"bot"`
+	Sensor    Sensor    `json:"sensor"`
+	Attempts  int       `json:"attempts"`
+	LastError string    `json:"last_error"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// moves the resilience entry (bot, sensor message) to dead letters once retry budget is exhausted
+func (eb *Broker) deadLetter(bot Bot, sensor Sensor, attempts int, lastErr error) {
+
+	var letter DeadLetter
+	letter.Id = shortuuid.New()
+	letter.BotId = bot.Id
+	letter.Sensor = sensor
+	letter.Attempts = attempts
+	letter.LastError = lastErr.Error()
+	letter.Timestamp = time.Now()
+
+	// dead letter is written before removing resilience entry, so a crash in between can only cause a duplicate
+	if err := eb.repo.AddDeadLetter(letter); err != nil {
+		panic(err.Error())
+	}
+	eb.removeResilienceEntry(bot.Id, sensor.MessageId, sensor.Id)
+}
+
+// moves every message bot has still to ack to dead letters, bot being removed will never ack them
+func (eb *Broker) deadLetterPending(bot Bot) {
+	eb.deadLetterLock.Lock()
+	defer eb.deadLetterLock.Unlock()
+
+	resilience, err := eb.repo.GetBotResilienceEntries(bot.Id)
+	if err != nil {
+		panic(err.Error())
+	}
+	for _, resilienceItem := range resilience {
+		eb.deadLetter(bot, resilienceItem.Sensor, 0, errors.New("bot "+bot.Id+" was removed"))
+	}
+}
+
+// delivers again dead letter message to its bot, with a fresh retry budget, as a copy with its own
+// sensor request and resilience entry, written first so the redelivery survives a crash
+func (eb *Broker) Requeue(letter DeadLetter, bot Bot) error {
+
+	if _, err := eb.publishTo([]Bot{bot}, letter.Sensor.copy(), true, nil); err != nil {
+		return err
+	}
+	return eb.repo.RemoveDeadLetter(letter.Id)
+}
@@ -6,160 +6,226 @@ import (
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"strconv"
+	"time"
 )
 
-func initDBClient() *dynamodb.DynamoDB {
+// DynamoDBRepository is the Repository backed by AWS DynamoDB service
+type DynamoDBRepository struct {
+	client *dynamodb.DynamoDB
+}
 
-	if dynamoDBSession == nil {
+// global secondary index of resilience table on bot, so messages of a bot are read without a scan
+const resilienceBotIndex = "bot-index"
 
-		sess := session.Must(session.NewSessionWithOptions(session.Options{
-			SharedConfigState: session.SharedConfigEnable,
-		}))
+// creates a DynamoDB client from shared AWS configuration (env variables or ~/.aws)
+func NewDynamoDBRepository() *DynamoDBRepository {
 
-		dynamoDBSession = dynamodb.New(sess)
-	}
+	sess := session.Must(session.NewSessionWithOptions(session.Options{
+		SharedConfigState: session.SharedConfigEnable,
+	}))
 
-	return dynamoDBSession
+	return &DynamoDBRepository{client: dynamodb.New(sess)}
 }
 
 //add bot to DB
-func AddDBBot(bot Bot) {
-	client := initDBClient()
+func (repo *DynamoDBRepository) AddBot(bot Bot) error {
+	client := repo.client
 	av, err := dynamodbattribute.MarshalMap(bot)
+	if err != nil {
+		return err
+	}
 	input := &dynamodb.PutItemInput{
 		Item:      av,
 		TableName: aws.String("bots"),
 	}
 	_, err = client.PutItem(input)
-	if err != nil {
-		panic(err.Error())
-	}
+	return err
 }
 
 // return the bot list in db if any
-func GetDBBots() ([]Bot, error) {
-	client := initDBClient()
-	params := &dynamodb.ScanInput{
-		TableName: aws.String("bots"),
-	}
-
-	result, err := client.Scan(params)
-	if err != nil {
-		return nil, err
-	}
-
+func (repo *DynamoDBRepository) GetBots() ([]Bot, error) {
 	var botslist = []Bot{}
-	for _, i := range result.Items {
-		bot := Bot{}
-		err = dynamodbattribute.UnmarshalMap(i, &bot)
-		if err != nil {
-			return nil, err
+	err := repo.scanPages("bots", func(items []map[string]*dynamodb.AttributeValue) error {
+		pageList := []Bot{}
+		if err := dynamodbattribute.UnmarshalListOfMaps(items, &pageList); err != nil {
+			return err
 		}
-		botslist = append(botslist, bot)
+		botslist = append(botslist, pageList...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 	return botslist, nil
 }
 
 // return the list of botIds and their own messages which need to be retransmitted
-func GetResilienceEntries() ([]resilienceEntry, error) {
-	client := initDBClient()
-	params := &dynamodb.ScanInput{
-		TableName: aws.String("resilience"),
-	}
-
-	result, err := client.Scan(params)
+func (repo *DynamoDBRepository) GetResilienceEntries() ([]resilienceEntry, error) {
+	var resilienceList = []resilienceEntry{}
+	err := repo.scanPages("resilience", func(items []map[string]*dynamodb.AttributeValue) error {
+		pageList := []resilienceEntry{}
+		if err := dynamodbattribute.UnmarshalListOfMaps(items, &pageList); err != nil {
+			return err
+		}
+		resilienceList = append(resilienceList, pageList...)
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
+	return resilienceList, nil
+}
+
+// return the messages bot has still to ack, through the index of resilience table on bot
+func (repo *DynamoDBRepository) GetBotResilienceEntries(botId string) ([]resilienceEntry, error) {
+	client := repo.client
+	params := &dynamodb.QueryInput{
+		IndexName:              aws.String(resilienceBotIndex),
+		KeyConditionExpression: aws.String("#bot = :bot"),
+		ExpressionAttributeNames: map[string]*string{
+			"#bot": aws.String("bot"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":bot": {S: aws.String(botId)},
+		},
+		TableName: aws.String("resilience"),
+	}
 
 	var resilienceList = []resilienceEntry{}
-	for _, i := range result.Items {
-		entry := resilienceEntry{}
-		err = dynamodbattribute.UnmarshalMap(i, &entry)
-		if err != nil {
-			return nil, err
+	var unmarshalErr error
+	err := client.QueryPages(params, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+		pageList := []resilienceEntry{}
+		if unmarshalErr = dynamodbattribute.UnmarshalListOfMaps(page.Items, &pageList); unmarshalErr != nil {
+			return false
 		}
-		resilienceList = append(resilienceList, entry)
+		resilienceList = append(resilienceList, pageList...)
+		return true
+	})
+	if err != nil {
+		return nil, err
 	}
-	return resilienceList, nil
+	return resilienceList, unmarshalErr
 }
 
-// return the list of sensor's publish requests which need to be retransmitted
-func GetRequestEntries() ([]Sensor, error) {
-	client := initDBClient()
+// scans every page of table, a single Scan returns at most 1 MB of items
+func (repo *DynamoDBRepository) scanPages(table string, add func(items []map[string]*dynamodb.AttributeValue) error) error {
 	params := &dynamodb.ScanInput{
-		TableName: aws.String("sensorsRequest"),
+		TableName: aws.String(table),
 	}
 
-	result, err := client.Scan(params)
+	var addErr error
+	err := repo.client.ScanPages(params, func(page *dynamodb.ScanOutput, lastPage bool) bool {
+		addErr = add(page.Items)
+		return addErr == nil
+	})
 	if err != nil {
-		return nil, err
+		return err
 	}
+	return addErr
+}
 
+// return the list of sensor's publish requests which need to be retransmitted
+func (repo *DynamoDBRepository) GetRequestEntries() ([]Sensor, error) {
 	var requestList = []Sensor{}
-	for _, i := range result.Items {
-		entry := Sensor{}
-		err = dynamodbattribute.UnmarshalMap(i, &entry)
-		if err != nil {
-			return nil, err
+	err := repo.scanPages("sensorsRequest", func(items []map[string]*dynamodb.AttributeValue) error {
+		pageList := []Sensor{}
+		if err := dynamodbattribute.UnmarshalListOfMaps(items, &pageList); err != nil {
+			return err
 		}
-		requestList = append(requestList, entry)
+		requestList = append(requestList, pageList...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
-
 	return requestList, nil
 }
 
+// return the sensor's publish request (sensorId, msgId) if it is still in db
+func (repo *DynamoDBRepository) GetSensorRequest(sensorId string, msgId string) (Sensor, error) {
+	client := repo.client
+	params := &dynamodb.GetItemInput{
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {
+				S: aws.String(sensorId),
+			},
+			"msg_id": {
+				S: aws.String(msgId),
+			},
+		},
+		TableName: aws.String("sensorsRequest"),
+	}
+
+	var request Sensor
+	result, err := client.GetItem(params)
+	if err != nil {
+		return request, err
+	}
+
+	err = dynamodbattribute.UnmarshalMap(result.Item, &request)
+	return request, err
+}
+
 //add sensor to DB
-func AddDBSensorRequest(sensor Sensor) {
-	client := initDBClient()
+func (repo *DynamoDBRepository) AddSensorRequest(sensor Sensor) error {
+	client := repo.client
 	av, err := dynamodbattribute.MarshalMap(sensor)
+	if err != nil {
+		return err
+	}
 	input := &dynamodb.PutItemInput{
 		Item:      av,
 		TableName: aws.String("sensorsRequest"),
 	}
 	_, err = client.PutItem(input)
-	if err != nil {
-		panic(err.Error())
-	}
+	return err
 }
 
 //add every bot id and the message to be sent to this bot in resilience DynamoDb table
-func writeBotIdsAndMessage(botsArray []Bot, sensor Sensor) {
+func (repo *DynamoDBRepository) WriteBotIdsAndMessage(botsArray []Bot, sensor Sensor) error {
 
-	client := initDBClient()
+	client := repo.client
 
 	for _, bot := range botsArray {
 
 		var item resilienceEntry
 		item.Id = bot.Id + sensor.Id
 		item.Message = sensor.Message
+		item.MessageId = sensor.MessageId
+		item.BotId = bot.Id
+		item.Sensor = sensor
 
 		av, err := dynamodbattribute.MarshalMap(item)
+		if err != nil {
+			return err
+		}
 		input := &dynamodb.PutItemInput{
 			Item:      av,
 			TableName: aws.String("resilience"),
 		}
 		_, err = client.PutItem(input)
 		if err != nil {
-			panic(err.Error())
+			return err
 		}
 	}
+	return nil
 }
 
 //removes the entry (botId,message) from resilience table if bot identified by botId received correctly message
 //and answered with an ack to the current transmitting goroutine
-func removeResilienceEntry(botId string, message string, sensor string) {
+func (repo *DynamoDBRepository) RemoveResilienceEntry(botId string, msgId string, sensor string) error {
 
-	client := initDBClient()
+	client := repo.client
 	id := botId + sensor
-	thisMessage := message
+	thisMessage := msgId
 
 	params := &dynamodb.DeleteItemInput{
 		Key: map[string]*dynamodb.AttributeValue{
 			"id": {
 				S: aws.String(id),
 			},
-			"message": {
+			"msg_id": {
 				S: aws.String(thisMessage),
 			},
 		},
@@ -167,27 +233,23 @@ func removeResilienceEntry(botId string, message string, sensor string) {
 	}
 
 	_, err := client.DeleteItem(params)
-	if err != nil {
-
-		panic(err.Error())
-
-	}
+	return err
 }
 
 //removes the entry (botId,message) from resilience table if bot identified by botId received correctly message
 //and answered with an ack to current transmitting goroutine
-func removePubRequest(sensorId string, message string) {
+func (repo *DynamoDBRepository) RemovePubRequest(sensorId string, msgId string) error {
 
-	client := initDBClient()
+	client := repo.client
 	id := sensorId
-	thisMessage := message
+	thisMessage := msgId
 
 	params := &dynamodb.DeleteItemInput{
 		Key: map[string]*dynamodb.AttributeValue{
 			"id": {
 				S: aws.String(id),
 			},
-			"msg": {
+			"msg_id": {
 				S: aws.String(thisMessage),
 			},
 		},
@@ -196,37 +258,160 @@ func removePubRequest(sensorId string, message string) {
 
 	_, err := client.DeleteItem(params)
 	if err != nil {
+		return err
+	}
+
+	fmt.Println("Deleted sensorsRequest entry : sensor  = " + id + "  and message id = " + thisMessage + "\n")
+	return nil
+}
+
+//func that checks if there are tables in dynamoDB
+func (repo *DynamoDBRepository) ExistingTables() (int, error) {
+
+	existing, err := repo.existingTableNames()
+	if err != nil {
+
+		return -1, err
 
-		panic(err.Error())
+	}
+
+	tablesNumber := 0
+	for _, name := range repositoryTables {
+		if existing[name] {
+			tablesNumber++
+		}
+	}
 
-	} else {
-		fmt.Println("Deleted sensorsRequest entry : sensor  = " + id + "  and message = " + thisMessage + "\n")
+	//a resilience table created before its index on bot is completed by CreateTables
+	if existing["resilience"] {
+		indexed, err := repo.hasResilienceBotIndex()
+		if err != nil {
+			return -1, err
+		}
+		if !indexed {
+			tablesNumber--
+		}
 	}
+	return tablesNumber, nil
+
 }
 
-//func that checks if there are tables in dynamoDB
-func ExistingTables() (int, error) {
+// returns the names of all tables in DynamoDB
+func (repo *DynamoDBRepository) existingTableNames() (map[string]bool, error) {
 
 	// create the input configuration instance
 	input := &dynamodb.ListTablesInput{}
 
-	client := initDBClient()
+	client := repo.client
+
+	existing := map[string]bool{}
 
 	// Get the list of tables
-	result, err := client.ListTables(input)
+	err := client.ListTablesPages(input, func(page *dynamodb.ListTablesOutput, lastPage bool) bool {
+		for _, name := range page.TableNames {
+			existing[*name] = true
+		}
+		return true
+	})
+	return existing, err
+}
+
+// tells if resilience table has its index on bot
+func (repo *DynamoDBRepository) hasResilienceBotIndex() (bool, error) {
+	output, err := repo.client.DescribeTable(&dynamodb.DescribeTableInput{TableName: aws.String("resilience")})
 	if err != nil {
+		return false, err
+	}
+	for _, index := range output.Table.GlobalSecondaryIndexes {
+		if *index.IndexName == resilienceBotIndex {
+			return true, nil
+		}
+	}
+	return false, nil
+}
 
-		return -1, err
+// returns index of resilience table on bot, with every attribute of the entries so they are read from it
+func resilienceBotIndexSpec() *dynamodb.GlobalSecondaryIndex {
+	return &dynamodb.GlobalSecondaryIndex{
+		IndexName: aws.String(resilienceBotIndex),
+		KeySchema: []*dynamodb.KeySchemaElement{
+			{
+				AttributeName: aws.String("bot"),
+				KeyType:       aws.String("HASH"),
+			},
+			{
+				AttributeName: aws.String("msg_id"),
+				KeyType:       aws.String("RANGE"),
+			},
+		},
+		Projection: &dynamodb.Projection{
+			ProjectionType: aws.String("ALL"),
+		},
+		ProvisionedThroughput: &dynamodb.ProvisionedThroughput{
+			ReadCapacityUnits:  aws.Int64(10),
+			WriteCapacityUnits: aws.Int64(10),
+		},
+	}
+}
+
+// creates table described by input unless it is already in existing tables
+func (repo *DynamoDBRepository) createTable(input *dynamodb.CreateTableInput, existing map[string]bool) error {
 
+	if existing[*input.TableName] {
+		return nil
 	}
 
-	return len(result.TableNames), nil
+	_, err := repo.client.CreateTable(input)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Created the table", *input.TableName)
+	return nil
+}
+
+// adds index on bot to a resilience table created without it
+func (repo *DynamoDBRepository) addResilienceBotIndex() error {
+	indexed, err := repo.hasResilienceBotIndex()
+	if err != nil || indexed {
+		return err
+	}
+
+	index := resilienceBotIndexSpec()
+	input := &dynamodb.UpdateTableInput{
+		AttributeDefinitions: []*dynamodb.AttributeDefinition{
+			{
+				AttributeName: aws.String("bot"),
+				AttributeType: aws.String("S"),
+			},
+			{
+				AttributeName: aws.String("msg_id"),
+				AttributeType: aws.String("S"),
+			},
+		},
+		GlobalSecondaryIndexUpdates: []*dynamodb.GlobalSecondaryIndexUpdate{
+			{
+				Create: &dynamodb.CreateGlobalSecondaryIndexAction{
+					IndexName:             index.IndexName,
+					KeySchema:             index.KeySchema,
+					Projection:            index.Projection,
+					ProvisionedThroughput: index.ProvisionedThroughput,
+				},
+			},
+		},
+		TableName: aws.String("resilience"),
+	}
+	if _, err := repo.client.UpdateTable(input); err != nil {
+		return err
+	}
 
+	fmt.Println("Created the index", resilienceBotIndex, "of table resilience")
+	return nil
 }
 
-func removeBot(id string) error {
+func (repo *DynamoDBRepository) RemoveBot(id string) error {
 
-	client := initDBClient()
+	client := repo.client
 
 	input := &dynamodb.DeleteItemInput{
 		TableName: aws.String("bots"),
@@ -246,10 +431,13 @@ func removeBot(id string) error {
 	return nil
 }
 
-//creates new Bots and Sensors tables
-func createTables() {
+//creates missing tables among bots, sensorsRequest, resilience, deadLetters, topics, history, retained and settings
+func (repo *DynamoDBRepository) CreateTables() error {
 
-	client := initDBClient()
+	existing, err := repo.existingTableNames()
+	if err != nil {
+		return err
+	}
 
 	// Create table bots
 	tableNameBots := "bots"
@@ -275,13 +463,10 @@ func createTables() {
 		TableName: aws.String(tableNameBots),
 	}
 
-	_, err := client.CreateTable(inputBots)
-	if err != nil {
-		panic(err.Error())
+	if err := repo.createTable(inputBots, existing); err != nil {
+		return err
 	}
 
-	fmt.Println("Created the table", tableNameBots)
-
 	// Create table sensorsRequest
 	tableSensorsRequest := "sensorsRequest"
 
@@ -292,7 +477,7 @@ func createTables() {
 				AttributeType: aws.String("S"),
 			},
 			{
-				AttributeName: aws.String("msg"),
+				AttributeName: aws.String("msg_id"),
 				AttributeType: aws.String("S"),
 			},
 		},
@@ -302,7 +487,7 @@ func createTables() {
 				KeyType:       aws.String("HASH"),
 			},
 			{
-				AttributeName: aws.String("msg"),
+				AttributeName: aws.String("msg_id"),
 				KeyType:       aws.String("RANGE"),
 			},
 		},
@@ -314,15 +499,10 @@ func createTables() {
 		TableName: aws.String(tableSensorsRequest),
 	}
 
-	_, err2 := client.CreateTable(inputSensors)
-	if err2 != nil {
-
-		panic(err2.Error())
-
+	if err := repo.createTable(inputSensors, existing); err != nil {
+		return err
 	}
 
-	fmt.Println("Created the table", tableSensorsRequest)
-
 	// Create table resilience
 	tableNameResilience := "resilience"
 
@@ -333,7 +513,11 @@ func createTables() {
 				AttributeType: aws.String("S"),
 			},
 			{
-				AttributeName: aws.String("message"),
+				AttributeName: aws.String("msg_id"),
+				AttributeType: aws.String("S"),
+			},
+			{
+				AttributeName: aws.String("bot"),
 				AttributeType: aws.String("S"),
 			},
 		},
@@ -343,10 +527,11 @@ func createTables() {
 				KeyType:       aws.String("HASH"),
 			},
 			{
-				AttributeName: aws.String("message"),
+				AttributeName: aws.String("msg_id"),
 				KeyType:       aws.String("RANGE"),
 			},
 		},
+		GlobalSecondaryIndexes: []*dynamodb.GlobalSecondaryIndex{resilienceBotIndexSpec()},
 		ProvisionedThroughput: &dynamodb.ProvisionedThroughput{
 			ReadCapacityUnits:  aws.Int64(10),
 			WriteCapacityUnits: aws.Int64(10),
@@ -355,13 +540,495 @@ func createTables() {
 		TableName: aws.String(tableNameResilience),
 	}
 
-	_, err3 := client.CreateTable(inputResilience)
-	if err3 != nil {
+	if err := repo.createTable(inputResilience, existing); err != nil {
+		return err
+	}
+	if existing[tableNameResilience] {
+		if err := repo.addResilienceBotIndex(); err != nil {
+			return err
+		}
+	}
 
-		panic(err3.Error())
+	// Create table deadLetters
+	tableNameDeadLetters := "deadLetters"
 
+	inputDeadLetters := &dynamodb.CreateTableInput{
+		AttributeDefinitions: []*dynamodb.AttributeDefinition{
+			{
+				AttributeName: aws.String("id"),
+				AttributeType: aws.String("S"),
+			},
+		},
+		KeySchema: []*dynamodb.KeySchemaElement{
+			{
+				AttributeName: aws.String("id"),
+				KeyType:       aws.String("HASH"),
+			},
+		},
+		ProvisionedThroughput: &dynamodb.ProvisionedThroughput{
+			ReadCapacityUnits:  aws.Int64(10),
+			WriteCapacityUnits: aws.Int64(10),
+		},
+
+		TableName: aws.String(tableNameDeadLetters),
 	}
 
-	fmt.Println("Created the table", tableNameResilience)
+	if err := repo.createTable(inputDeadLetters, existing); err != nil {
+		return err
+	}
+
+	// Create table topics
+	tableNameTopics := "topics"
 
+	inputTopics := &dynamodb.CreateTableInput{
+		AttributeDefinitions: []*dynamodb.AttributeDefinition{
+			{
+				AttributeName: aws.String("name"),
+				AttributeType: aws.String("S"),
+			},
+		},
+		KeySchema: []*dynamodb.KeySchemaElement{
+			{
+				AttributeName: aws.String("name"),
+				KeyType:       aws.String("HASH"),
+			},
+		},
+		ProvisionedThroughput: &dynamodb.ProvisionedThroughput{
+			ReadCapacityUnits:  aws.Int64(10),
+			WriteCapacityUnits: aws.Int64(10),
+		},
+
+		TableName: aws.String(tableNameTopics),
+	}
+
+	if err := repo.createTable(inputTopics, existing); err != nil {
+		return err
+	}
+
+	// Create table history
+	tableNameHistory := "history"
+
+	inputHistory := &dynamodb.CreateTableInput{
+		AttributeDefinitions: []*dynamodb.AttributeDefinition{
+			{
+				AttributeName: aws.String("type"),
+				AttributeType: aws.String("S"),
+			},
+			{
+				AttributeName: aws.String("offset"),
+				AttributeType: aws.String("N"),
+			},
+		},
+		KeySchema: []*dynamodb.KeySchemaElement{
+			{
+				AttributeName: aws.String("type"),
+				KeyType:       aws.String("HASH"),
+			},
+			{
+				AttributeName: aws.String("offset"),
+				KeyType:       aws.String("RANGE"),
+			},
+		},
+		ProvisionedThroughput: &dynamodb.ProvisionedThroughput{
+			ReadCapacityUnits:  aws.Int64(10),
+			WriteCapacityUnits: aws.Int64(10),
+		},
+
+		TableName: aws.String(tableNameHistory),
+	}
+
+	if err := repo.createTable(inputHistory, existing); err != nil {
+		return err
+	}
+
+	// Create table retained
+	tableNameRetained := "retained"
+
+	inputRetained := &dynamodb.CreateTableInput{
+		AttributeDefinitions: []*dynamodb.AttributeDefinition{
+			{
+				AttributeName: aws.String("type"),
+				AttributeType: aws.String("S"),
+			},
+			{
+				AttributeName: aws.String("current_sector"),
+				AttributeType: aws.String("S"),
+			},
+		},
+		KeySchema: []*dynamodb.KeySchemaElement{
+			{
+				AttributeName: aws.String("type"),
+				KeyType:       aws.String("HASH"),
+			},
+			{
+				AttributeName: aws.String("current_sector"),
+				KeyType:       aws.String("RANGE"),
+			},
+		},
+		ProvisionedThroughput: &dynamodb.ProvisionedThroughput{
+			ReadCapacityUnits:  aws.Int64(10),
+			WriteCapacityUnits: aws.Int64(10),
+		},
+
+		TableName: aws.String(tableNameRetained),
+	}
+
+	if err := repo.createTable(inputRetained, existing); err != nil {
+		return err
+	}
+
+	// Create table settings
+	tableNameSettings := "settings"
+
+	inputSettings := &dynamodb.CreateTableInput{
+		AttributeDefinitions: []*dynamodb.AttributeDefinition{
+			{
+				AttributeName: aws.String("name"),
+				AttributeType: aws.String("S"),
+			},
+		},
+		KeySchema: []*dynamodb.KeySchemaElement{
+			{
+				AttributeName: aws.String("name"),
+				KeyType:       aws.String("HASH"),
+			},
+		},
+		ProvisionedThroughput: &dynamodb.ProvisionedThroughput{
+			ReadCapacityUnits:  aws.Int64(10),
+			WriteCapacityUnits: aws.Int64(10),
+		},
+
+		TableName: aws.String(tableNameSettings),
+	}
+
+	if err := repo.createTable(inputSettings, existing); err != nil {
+		return err
+	}
+
+	// tables are not usable until DynamoDB marks them as active
+	time.Sleep(10 * time.Second)
+
+	return nil
+}
+
+//add dead letter to DB
+func (repo *DynamoDBRepository) AddDeadLetter(letter DeadLetter) error {
+	client := repo.client
+	av, err := dynamodbattribute.MarshalMap(letter)
+	if err != nil {
+		return err
+	}
+	input := &dynamodb.PutItemInput{
+		Item:      av,
+		TableName: aws.String("deadLetters"),
+	}
+	_, err = client.PutItem(input)
+	return err
+}
+
+// return the list of messages which bots never acked
+func (repo *DynamoDBRepository) GetDeadLetters() ([]DeadLetter, error) {
+	var deadList = []DeadLetter{}
+	err := repo.scanPages("deadLetters", func(items []map[string]*dynamodb.AttributeValue) error {
+		pageList := []DeadLetter{}
+		if err := dynamodbattribute.UnmarshalListOfMaps(items, &pageList); err != nil {
+			return err
+		}
+		deadList = append(deadList, pageList...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return deadList, nil
+}
+
+func (repo *DynamoDBRepository) GetDeadLetter(id string) (DeadLetter, error) {
+	client := repo.client
+	params := &dynamodb.GetItemInput{
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {
+				S: aws.String(id),
+			},
+		},
+		TableName: aws.String("deadLetters"),
+	}
+
+	var letter DeadLetter
+	result, err := client.GetItem(params)
+	if err != nil {
+		return letter, err
+	}
+
+	err = dynamodbattribute.UnmarshalMap(result.Item, &letter)
+	return letter, err
+}
+
+func (repo *DynamoDBRepository) RemoveDeadLetter(id string) error {
+	client := repo.client
+	params := &dynamodb.DeleteItemInput{
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {
+				S: aws.String(id),
+			},
+		},
+		TableName: aws.String("deadLetters"),
+	}
+
+	_, err := client.DeleteItem(params)
+	return err
+}
+
+//add registered topic to DB, replacing the one with the same name
+func (repo *DynamoDBRepository) AddTopic(topic Topic) error {
+	client := repo.client
+	av, err := dynamodbattribute.MarshalMap(topic)
+	if err != nil {
+		return err
+	}
+	input := &dynamodb.PutItemInput{
+		Item:      av,
+		TableName: aws.String("topics"),
+	}
+	_, err = client.PutItem(input)
+	return err
+}
+
+// return the list of registered topics
+func (repo *DynamoDBRepository) GetTopics() ([]Topic, error) {
+	var topicList = []Topic{}
+	err := repo.scanPages("topics", func(items []map[string]*dynamodb.AttributeValue) error {
+		pageList := []Topic{}
+		if err := dynamodbattribute.UnmarshalListOfMaps(items, &pageList); err != nil {
+			return err
+		}
+		topicList = append(topicList, pageList...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return topicList, nil
+}
+
+func (repo *DynamoDBRepository) RemoveTopic(name string) error {
+	client := repo.client
+	params := &dynamodb.DeleteItemInput{
+		Key: map[string]*dynamodb.AttributeValue{
+			"name": {
+				S: aws.String(name),
+			},
+		},
+		TableName: aws.String("topics"),
+	}
+
+	_, err := client.DeleteItem(params)
+	return err
+}
+
+//append message to the log of its topic in DB
+func (repo *DynamoDBRepository) AddHistoryEntry(sensor Sensor) error {
+	client := repo.client
+	av, err := dynamodbattribute.MarshalMap(sensor)
+	if err != nil {
+		return err
+	}
+	input := &dynamodb.PutItemInput{
+		Item:      av,
+		TableName: aws.String("history"),
+	}
+	_, err = client.PutItem(input)
+	return err
+}
+
+// return messages of topic between fromOffset and toOffset, in offset order, the first limit ones if limit is not 0
+func (repo *DynamoDBRepository) GetHistory(topic string, fromOffset int64, toOffset int64, limit int) ([]Sensor, error) {
+	params := &dynamodb.QueryInput{
+		KeyConditionExpression: aws.String("#type = :type AND #offset BETWEEN :from AND :to"),
+		ExpressionAttributeNames: map[string]*string{
+			"#type":   aws.String("type"),
+			"#offset": aws.String("offset"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":type": {S: aws.String(topic)},
+			":from": {N: aws.String(strconv.FormatInt(fromOffset, 10))},
+			":to":   {N: aws.String(strconv.FormatInt(toOffset, 10))},
+		},
+		TableName: aws.String("history"),
+	}
+	if limit > 0 {
+		params.Limit = aws.Int64(int64(limit))
+	}
+	return repo.queryHistory(params, limit)
+}
+
+// return the last n messages of topic, in offset order, reading them backwards from the end of its log
+func (repo *DynamoDBRepository) GetLastHistory(topic string, n int) ([]Sensor, error) {
+	params := &dynamodb.QueryInput{
+		KeyConditionExpression: aws.String("#type = :type"),
+		ExpressionAttributeNames: map[string]*string{
+			"#type": aws.String("type"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":type": {S: aws.String(topic)},
+		},
+		ScanIndexForward: aws.Bool(false),
+		Limit:            aws.Int64(int64(n)),
+		TableName:        aws.String("history"),
+	}
+
+	historyList, err := repo.queryHistory(params, n)
+	if err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(historyList)-1; i < j; i, j = i+1, j-1 {
+		historyList[i], historyList[j] = historyList[j], historyList[i]
+	}
+	return historyList, nil
+}
+
+// return messages of history matching query, stopping at limit of them if limit is not 0
+func (repo *DynamoDBRepository) queryHistory(params *dynamodb.QueryInput, limit int) ([]Sensor, error) {
+	var historyList = []Sensor{}
+	var unmarshalErr error
+	err := repo.client.QueryPages(params, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+		for _, i := range page.Items {
+			sensor := Sensor{}
+			if unmarshalErr = dynamodbattribute.UnmarshalMap(i, &sensor); unmarshalErr != nil {
+				return false
+			}
+			historyList = append(historyList, sensor)
+			if limit > 0 && len(historyList) == limit {
+				return false
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return historyList, unmarshalErr
+}
+
+func (repo *DynamoDBRepository) RemoveHistory(topic string, untilOffset int64) error {
+	historyList, err := repo.GetHistory(topic, 0, untilOffset, 0)
+	if err != nil {
+		return err
+	}
+
+	client := repo.client
+	for _, sensor := range historyList {
+		params := &dynamodb.DeleteItemInput{
+			Key: map[string]*dynamodb.AttributeValue{
+				"type": {
+					S: aws.String(topic),
+				},
+				"offset": {
+					N: aws.String(strconv.FormatInt(sensor.Offset, 10)),
+				},
+			},
+			TableName: aws.String("history"),
+		}
+		if _, err := client.DeleteItem(params); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//add last message of a topic and sector to DB, replacing the previous one
+func (repo *DynamoDBRepository) AddRetained(sensor Sensor) error {
+	client := repo.client
+	av, err := dynamodbattribute.MarshalMap(sensor)
+	if err != nil {
+		return err
+	}
+	input := &dynamodb.PutItemInput{
+		Item:      av,
+		TableName: aws.String("retained"),
+	}
+	_, err = client.PutItem(input)
+	return err
+}
+
+// return the last message of every topic and sector
+func (repo *DynamoDBRepository) GetRetained() ([]Sensor, error) {
+	var retainedList = []Sensor{}
+	err := repo.scanPages("retained", func(items []map[string]*dynamodb.AttributeValue) error {
+		pageList := []Sensor{}
+		if err := dynamodbattribute.UnmarshalListOfMaps(items, &pageList); err != nil {
+			return err
+		}
+		retainedList = append(retainedList, pageList...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return retainedList, nil
+}
+
+func (repo *DynamoDBRepository) RemoveRetained(topic string, sector string) error {
+	client := repo.client
+	params := &dynamodb.DeleteItemInput{
+		Key: map[string]*dynamodb.AttributeValue{
+			"type": {
+				S: aws.String(topic),
+			},
+			"current_sector": {
+				S: aws.String(sector),
+			},
+		},
+		TableName: aws.String("retained"),
+	}
+
+	_, err := client.DeleteItem(params)
+	return err
+}
+
+//add a broker setting to DB, replacing the previous value
+func (repo *DynamoDBRepository) AddSetting(setting Setting) error {
+	client := repo.client
+	av, err := dynamodbattribute.MarshalMap(setting)
+	if err != nil {
+		return err
+	}
+	input := &dynamodb.PutItemInput{
+		Item:      av,
+		TableName: aws.String("settings"),
+	}
+	_, err = client.PutItem(input)
+	return err
+}
+
+// return every broker setting changed at runtime
+func (repo *DynamoDBRepository) GetSettings() ([]Setting, error) {
+	var settingList = []Setting{}
+	err := repo.scanPages("settings", func(items []map[string]*dynamodb.AttributeValue) error {
+		pageList := []Setting{}
+		if err := dynamodbattribute.UnmarshalListOfMaps(items, &pageList); err != nil {
+			return err
+		}
+		settingList = append(settingList, pageList...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return settingList, nil
+}
+
+func (repo *DynamoDBRepository) RemoveSetting(name string) error {
+	client := repo.client
+	params := &dynamodb.DeleteItemInput{
+		Key: map[string]*dynamodb.AttributeValue{
+			"name": {
+				S: aws.String(name),
+			},
+		},
+		TableName: aws.String("settings"),
+	}
+
+	_, err := client.DeleteItem(params)
+	return err
 }
@@ -0,0 +1,644 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// FileRepository is a Repository stored on local disk, for brokers which cannot reach DynamoDB.
+// Tables are served from an in-memory copy and every table is an append-only journal of json lines in its
+// own file: a change is written and synced before memory is changed, so after a crash the broker finds exactly
+// the state acknowledged to sensors and bots. The log of every topic is an append-only segment in historyDir
+type FileRepository struct {
+	*MemoryRepository
+
+	dir string
+
+	botsTable       *fileTable
+	requestsTable   *fileTable
+	resilienceTable *fileTable
+	deadTable       *fileTable
+	topicsTable     *fileTable
+	retainedTable   *fileTable
+	settingsTable   *fileTable
+
+	segmentLocks map[string]*sync.Mutex // serializes writes to the history segment of every topic
+	segmentLock  sync.Mutex             // protects segmentLocks
+}
+
+const (
+	botsFile       = "bots.jsonl"
+	requestsFile   = "sensorsRequest.jsonl"
+	resilienceFile = "resilience.jsonl"
+	deadFile       = "deadLetters.jsonl"
+	topicsFile     = "topics.jsonl"
+	historyDir     = "history"
+	retainedFile   = "retained.jsonl"
+	settingsFile   = "settings.jsonl"
+
+	segmentSuffix = ".jsonl"
+
+	minCompactLines = 1024 // a journal is never compacted before it has this many lines
+)
+
+// line of a table journal, which puts items or removes the one with key
+type journalLine struct {
+	Put    []json.RawMessage `json:"put,omitempty"`
+	Remove []string          `json:"remove,omitempty"`
+}
+
+// fileTable is a table of FileRepository. Stored lines are applied to memory with put and remove,
+// both when they are written and when the journal is loaded, so memory is always what a reload would find.
+// Once the journal has twice the lines of live items it is compacted into a single line putting them all
+type fileTable struct {
+	path   string
+	put    func(item json.RawMessage) error // stores item in memory
+	remove func(key []string)               // removes item with key from memory
+	items  func() (interface{}, int)        // live items in memory and their number
+
+	lock      sync.Mutex // serializes writes so lines are applied in the same order they are stored
+	journal   *os.File
+	size      int64 // bytes stored in journal, a failed append is truncated back to it
+	lines     int
+	compactAt int
+}
+
+// opens the repository stored in dir, loading tables already written by a previous run
+func NewFileRepository(dir string) (*FileRepository, error) {
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	repo := &FileRepository{
+		MemoryRepository: NewMemoryRepository(),
+		dir:              dir,
+		segmentLocks:     map[string]*sync.Mutex{},
+	}
+	memory := repo.MemoryRepository
+
+	repo.botsTable = repo.table(botsFile,
+		func(item json.RawMessage) error {
+			var bot Bot
+			err := json.Unmarshal(item, &bot)
+			if err == nil {
+				memory.AddBot(bot)
+			}
+			return err
+		},
+		func(key []string) { memory.RemoveBot(key[0]) },
+		func() (interface{}, int) {
+			botsList, _ := memory.GetBots()
+			return botsList, len(botsList)
+		})
+
+	repo.requestsTable = repo.table(requestsFile,
+		func(item json.RawMessage) error {
+			var sensor Sensor
+			err := json.Unmarshal(item, &sensor)
+			if err == nil {
+				memory.AddSensorRequest(sensor)
+			}
+			return err
+		},
+		func(key []string) { memory.RemovePubRequest(key[0], key[1]) },
+		func() (interface{}, int) {
+			requestList, _ := memory.GetRequestEntries()
+			return requestList, len(requestList)
+		})
+
+	repo.resilienceTable = repo.table(resilienceFile,
+		func(item json.RawMessage) error {
+			var entry resilienceEntry
+			err := json.Unmarshal(item, &entry)
+			if err == nil {
+				memory.putResilienceEntry(entry)
+			}
+			return err
+		},
+		func(key []string) { memory.RemoveResilienceEntry(key[0], key[1], key[2]) },
+		func() (interface{}, int) {
+			resilienceList, _ := memory.GetResilienceEntries()
+			return resilienceList, len(resilienceList)
+		})
+
+	repo.deadTable = repo.table(deadFile,
+		func(item json.RawMessage) error {
+			var letter DeadLetter
+			err := json.Unmarshal(item, &letter)
+			if err == nil {
+				memory.AddDeadLetter(letter)
+			}
+			return err
+		},
+		func(key []string) { memory.RemoveDeadLetter(key[0]) },
+		func() (interface{}, int) {
+			deadList, _ := memory.GetDeadLetters()
+			return deadList, len(deadList)
+		})
+
+	repo.topicsTable = repo.table(topicsFile,
+		func(item json.RawMessage) error {
+			var topic Topic
+			err := json.Unmarshal(item, &topic)
+			if err == nil {
+				memory.AddTopic(topic)
+			}
+			return err
+		},
+		func(key []string) { memory.RemoveTopic(key[0]) },
+		func() (interface{}, int) {
+			topicList, _ := memory.GetTopics()
+			return topicList, len(topicList)
+		})
+
+	repo.retainedTable = repo.table(retainedFile,
+		func(item json.RawMessage) error {
+			var sensor Sensor
+			err := json.Unmarshal(item, &sensor)
+			if err == nil {
+				memory.AddRetained(sensor)
+			}
+			return err
+		},
+		func(key []string) { memory.RemoveRetained(key[0], key[1]) },
+		func() (interface{}, int) {
+			retainedList, _ := memory.GetRetained()
+			return retainedList, len(retainedList)
+		})
+
+	repo.settingsTable = repo.table(settingsFile,
+		func(item json.RawMessage) error {
+			var setting Setting
+			err := json.Unmarshal(item, &setting)
+			if err == nil {
+				memory.AddSetting(setting)
+			}
+			return err
+		},
+		func(key []string) { memory.RemoveSetting(key[0]) },
+		func() (interface{}, int) {
+			settingList, _ := memory.GetSettings()
+			return settingList, len(settingList)
+		})
+
+	for _, table := range repo.tables() {
+		if err := table.load(); err != nil {
+			return nil, err
+		}
+	}
+	if err := repo.loadHistory(); err != nil {
+		return nil, err
+	}
+
+	return repo, nil
+}
+
+func (repo *FileRepository) table(name string, put func(json.RawMessage) error, remove func([]string), items func() (interface{}, int)) *fileTable {
+	return &fileTable{path: filepath.Join(repo.dir, name), put: put, remove: remove, items: items, compactAt: minCompactLines}
+}
+
+func (repo *FileRepository) tables() []*fileTable {
+	return []*fileTable{repo.botsTable, repo.requestsTable, repo.resilienceTable, repo.deadTable, repo.topicsTable, repo.retainedTable, repo.settingsTable}
+}
+
+// reads the json lines of the journal at path and passes them to read in order, a missing file is an empty journal.
+// A crash in the middle of an append may leave a partial last line, which is dropped by truncating the journal
+// before it; a bad line followed by others is instead an error, since the lines after it were acknowledged
+func readJournal(path string, read func(line []byte) error) error {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	start := 0
+	for start < len(data) {
+		end := bytes.IndexByte(data[start:], '\n')
+		if end < 0 {
+			break
+		}
+		end += start
+		if err := read(data[start:end]); err != nil {
+			if end+1 < len(data) {
+				return fmt.Errorf("%s: bad entry at byte %d: %v", path, start, err)
+			}
+			break
+		}
+		start = end + 1
+	}
+
+	if start < len(data) {
+		fmt.Println("Dropping partial last entry of", path)
+		return os.Truncate(path, int64(start))
+	}
+	return nil
+}
+
+// applies the journal of table to memory
+func (table *fileTable) load() error {
+	table.lock.Lock()
+	defer table.lock.Unlock()
+
+	err := readJournal(table.path, func(data []byte) error {
+		var line journalLine
+		if err := json.Unmarshal(data, &line); err != nil {
+			return err
+		}
+		table.lines++
+		return table.apply(line)
+	})
+	if err != nil {
+		return err
+	}
+
+	_, live := table.items()
+	table.compactAt = compactThreshold(live)
+	return nil
+}
+
+func compactThreshold(live int) int {
+	if 2*live > minCompactLines {
+		return 2 * live
+	}
+	return minCompactLines
+}
+
+func (table *fileTable) apply(line journalLine) error {
+	for _, item := range line.Put {
+		if err := table.put(item); err != nil {
+			return err
+		}
+	}
+	if len(line.Remove) > 0 {
+		table.remove(line.Remove)
+	}
+	return nil
+}
+
+// opens the journal for appending, creating it if missing. Callers must hold table.lock
+func (table *fileTable) open() error {
+	if table.journal != nil {
+		return nil
+	}
+	file, err := os.OpenFile(table.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	table.journal = file
+	table.size = info.Size()
+	return nil
+}
+
+// stores items in a single line, so either all of them or none are found after a crash
+func (table *fileTable) putItems(items ...interface{}) error {
+	var line journalLine
+	for _, item := range items {
+		data, err := json.Marshal(item)
+		if err != nil {
+			return err
+		}
+		line.Put = append(line.Put, data)
+	}
+	return table.write(line)
+}
+
+func (table *fileTable) removeItem(key ...string) error {
+	return table.write(journalLine{Remove: key})
+}
+
+// appends line to the journal and syncs it, then applies it to memory. A failed append is truncated away
+// and memory is left untouched, so the journal never has a line which memory has not
+func (table *fileTable) write(line journalLine) error {
+	data, err := json.Marshal(line)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	table.lock.Lock()
+	defer table.lock.Unlock()
+
+	if err := table.open(); err != nil {
+		return err
+	}
+	if _, err = table.journal.Write(data); err == nil {
+		err = table.journal.Sync()
+	}
+	if err != nil {
+		table.journal.Truncate(table.size)
+		return err
+	}
+	table.size += int64(len(data))
+	table.lines++
+
+	if err := table.apply(line); err != nil {
+		return err
+	}
+
+	if table.lines >= table.compactAt {
+		// line is already stored, a journal which cannot be compacted now is just longer
+		if err := table.compact(); err != nil {
+			fmt.Println("Cannot compact", table.path, ":", err)
+		}
+	}
+	return nil
+}
+
+// writes again the journal with a single line putting every live item. Callers must hold table.lock
+func (table *fileTable) compact() error {
+	items, live := table.items()
+
+	var data []byte
+	if live > 0 {
+		line, err := json.Marshal(struct {
+			Put interface{} `json:"put"`
+		}{items})
+		if err != nil {
+			return err
+		}
+		data = append(line, '\n')
+	}
+	if err := writeAtomically(table.path, data); err != nil {
+		return err
+	}
+
+	// journal was renamed over, appends must go to the new file
+	table.journal.Close()
+	table.journal = nil
+	if err := table.open(); err != nil {
+		return err
+	}
+	table.lines = 0
+	if live > 0 {
+		table.lines = 1
+	}
+	table.compactAt = compactThreshold(live)
+	return nil
+}
+
+// writes data in a temporary file next to path and renames it over path
+func writeAtomically(path string, data []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// returns path of the history segment of topic, topic names may have levels so they are escaped
+func (repo *FileRepository) segmentOf(topic string) string {
+	return filepath.Join(repo.dir, historyDir, url.PathEscape(topic)+segmentSuffix)
+}
+
+// returns the lock of the history segment of topic
+func (repo *FileRepository) segmentLockOf(topic string) *sync.Mutex {
+	repo.segmentLock.Lock()
+	defer repo.segmentLock.Unlock()
+
+	lock, found := repo.segmentLocks[topic]
+	if !found {
+		lock = &sync.Mutex{}
+		repo.segmentLocks[topic] = lock
+	}
+	return lock
+}
+
+// loads every history segment, dropping a partial last line as table journals do
+func (repo *FileRepository) loadHistory() error {
+	files, err := ioutil.ReadDir(filepath.Join(repo.dir, historyDir))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, info := range files {
+		if !strings.HasSuffix(info.Name(), segmentSuffix) {
+			continue
+		}
+		err := readJournal(filepath.Join(repo.dir, historyDir, info.Name()), func(line []byte) error {
+			var sensor Sensor
+			if err := json.Unmarshal(line, &sensor); err != nil {
+				return err
+			}
+			return repo.MemoryRepository.AddHistoryEntry(sensor)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writes history segment at path with historyList atomically, removes it if historyList is empty
+func (repo *FileRepository) storeSegment(path string, historyList []Sensor) error {
+	if len(historyList) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	var data []byte
+	for _, sensor := range historyList {
+		line, err := json.Marshal(sensor)
+		if err != nil {
+			return err
+		}
+		data = append(append(data, line...), '\n')
+	}
+	return writeAtomically(path, data)
+}
+
+func (repo *FileRepository) ExistingTables() (int, error) {
+	tablesNumber := 0
+	for _, name := range []string{botsFile, requestsFile, resilienceFile, deadFile, topicsFile, historyDir, retainedFile, settingsFile} {
+		_, err := os.Stat(filepath.Join(repo.dir, name))
+		if err == nil {
+			tablesNumber++
+		} else if !os.IsNotExist(err) {
+			return -1, err
+		}
+	}
+	return tablesNumber, nil
+}
+
+// creates the journals missing, the ones already there are kept as they are
+func (repo *FileRepository) CreateTables() error {
+	for _, table := range repo.tables() {
+		table.lock.Lock()
+		err := table.open()
+		table.lock.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+	if err := os.MkdirAll(filepath.Join(repo.dir, historyDir), 0755); err != nil {
+		return err
+	}
+
+	fmt.Println("Created the tables in", repo.dir)
+	return nil
+}
+
+func (repo *FileRepository) AddBot(bot Bot) error {
+	return repo.botsTable.putItems(bot)
+}
+
+func (repo *FileRepository) RemoveBot(id string) error {
+	if err := repo.botsTable.removeItem(id); err != nil {
+		return err
+	}
+
+	fmt.Println("---- Bot " + id + " was successfully removed ")
+	return nil
+}
+
+func (repo *FileRepository) AddSensorRequest(sensor Sensor) error {
+	return repo.requestsTable.putItems(sensor)
+}
+
+func (repo *FileRepository) RemovePubRequest(sensorId string, msgId string) error {
+	if err := repo.requestsTable.removeItem(sensorId, msgId); err != nil {
+		return err
+	}
+
+	fmt.Println("Deleted sensorsRequest entry : sensor  = " + sensorId + "  and message id = " + msgId + "\n")
+	return nil
+}
+
+func (repo *FileRepository) WriteBotIdsAndMessage(botsArray []Bot, sensor Sensor) error {
+	var items []interface{}
+	for _, entry := range resilienceEntriesOf(botsArray, sensor) {
+		items = append(items, entry)
+	}
+	if len(items) == 0 {
+		return nil
+	}
+	return repo.resilienceTable.putItems(items...)
+}
+
+func (repo *FileRepository) RemoveResilienceEntry(botId string, msgId string, sensor string) error {
+	return repo.resilienceTable.removeItem(botId, msgId, sensor)
+}
+
+func (repo *FileRepository) AddDeadLetter(letter DeadLetter) error {
+	return repo.deadTable.putItems(letter)
+}
+
+func (repo *FileRepository) RemoveDeadLetter(id string) error {
+	return repo.deadTable.removeItem(id)
+}
+
+func (repo *FileRepository) AddTopic(topic Topic) error {
+	return repo.topicsTable.putItems(topic)
+}
+
+func (repo *FileRepository) RemoveTopic(name string) error {
+	return repo.topicsTable.removeItem(name)
+}
+
+// appends sensor message to the history segment of its topic, without rewriting the messages already there
+func (repo *FileRepository) AddHistoryEntry(sensor Sensor) error {
+	lock := repo.segmentLockOf(sensor.Type)
+	lock.Lock()
+	defer lock.Unlock()
+
+	line, err := json.Marshal(sensor)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Join(repo.dir, historyDir), 0755); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(repo.segmentOf(sensor.Type), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	if _, err = file.Write(append(line, '\n')); err == nil {
+		err = file.Sync()
+	}
+	if err != nil {
+		file.Truncate(info.Size())
+		file.Close()
+		return err
+	}
+	if err = file.Close(); err != nil {
+		return err
+	}
+
+	repo.MemoryRepository.AddHistoryEntry(sensor)
+	return nil
+}
+
+// writes again the history segment of topic with the messages left, which happens once every retention interval.
+// Memory is trimmed only once the segment is stored
+func (repo *FileRepository) RemoveHistory(topic string, untilOffset int64) error {
+	lock := repo.segmentLockOf(topic)
+	lock.Lock()
+	defer lock.Unlock()
+
+	entries, _ := repo.MemoryRepository.GetHistory(topic, 0, math.MaxInt64, 0)
+	historyList := []Sensor{}
+	for _, sensor := range entries {
+		if sensor.Offset > untilOffset {
+			historyList = append(historyList, sensor)
+		}
+	}
+	if err := repo.storeSegment(repo.segmentOf(topic), historyList); err != nil {
+		return err
+	}
+	return repo.MemoryRepository.RemoveHistory(topic, untilOffset)
+}
+
+func (repo *FileRepository) AddRetained(sensor Sensor) error {
+	return repo.retainedTable.putItems(sensor)
+}
+
+func (repo *FileRepository) RemoveRetained(topic string, sector string) error {
+	return repo.retainedTable.removeItem(topic, sector)
+}
+
+func (repo *FileRepository) AddSetting(setting Setting) error {
+	return repo.settingsTable.putItems(setting)
+}
+
+func (repo *FileRepository) RemoveSetting(name string) error {
+	return repo.settingsTable.removeItem(name)
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newTestFileRepository(t *testing.T, dir string) *FileRepository {
+	repo, err := NewFileRepository(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return repo
+}
+
+func TestFileRepositoryTables(t *testing.T) {
+	repo := newTestFileRepository(t, t.TempDir())
+
+	if tablesNumber, _ := repo.ExistingTables(); tablesNumber != 0 {
+		t.Fatalf("new repository has %d tables", tablesNumber)
+	}
+	if err := repo.CreateTables(); err != nil {
+		t.Fatal(err)
+	}
+	if tablesNumber, _ := repo.ExistingTables(); tablesNumber != len(repositoryTables) {
+		t.Fatalf("repository has %d tables after CreateTables, want %d", tablesNumber, len(repositoryTables))
+	}
+
+	// creating tables again must not lose what is stored
+	repo.AddBot(Bot{Id: "b1", Topic: "temperature"})
+	if err := repo.CreateTables(); err != nil {
+		t.Fatal(err)
+	}
+	if botsList, _ := newTestFileRepository(t, repo.dir).GetBots(); len(botsList) != 1 {
+		t.Errorf("got %+v after CreateTables on existing tables", botsList)
+	}
+}
+
+func TestFileRepositoryReload(t *testing.T) {
+	dir := t.TempDir()
+	repo := newTestFileRepository(t, dir)
+	repo.CreateTables()
+
+	repo.AddBot(Bot{Id: "b1", Topic: "temperature"})
+	repo.AddBot(Bot{Id: "b2", Topic: "humidity"})
+	repo.AddBot(Bot{Id: "b1", Topic: "motion"})
+	repo.RemoveBot("b2")
+
+	sensor := Sensor{Id: "s1", Message: "20", Type: "temperature", MessageId: "m1", Offset: 1}
+	repo.AddSensorRequest(sensor)
+	repo.AddSensorRequest(Sensor{Id: "s1", Message: "21", Type: "temperature", MessageId: "m2", Offset: 2})
+	repo.RemovePubRequest("s1", "m2")
+
+	repo.WriteBotIdsAndMessage([]Bot{{Id: "b1"}, {Id: "b3"}}, sensor)
+	repo.RemoveResilienceEntry("b3", "m1", "s1")
+
+	repo.AddDeadLetter(DeadLetter{Id: "d1", BotId: "b1", Sensor: sensor, Attempts: 3})
+	repo.AddDeadLetter(DeadLetter{Id: "d2", BotId: "b1", Sensor: sensor})
+	repo.RemoveDeadLetter("d2")
+
+	repo.AddTopic(Topic{Name: "temperature"})
+	repo.AddTopic(Topic{Name: "humidity"})
+	repo.RemoveTopic("humidity")
+
+	repo.AddHistoryEntry(sensor)
+	repo.AddHistoryEntry(Sensor{Id: "s1", Message: "21", Type: "temperature", MessageId: "m2", Offset: 2})
+	repo.AddHistoryEntry(Sensor{Id: "s1", Message: "22", Type: "temperature", MessageId: "m3", Offset: 3})
+	repo.RemoveHistory("temperature", 1)
+
+	repo.AddRetained(Sensor{Id: "s1", Message: "20", Type: "temperature", CurrentSector: "A"})
+	repo.AddRetained(Sensor{Id: "s1", Message: "20", Type: "temperature", CurrentSector: "B"})
+	repo.RemoveRetained("temperature", "B")
+
+	reloaded := newTestFileRepository(t, dir)
+
+	if botsList, _ := reloaded.GetBots(); len(botsList) != 1 || botsList[0].Topic != "motion" {
+		t.Errorf("got bots %+v", botsList)
+	}
+	if requestList, _ := reloaded.GetRequestEntries(); len(requestList) != 1 || requestList[0].MessageId != "m1" {
+		t.Errorf("got requests %+v", requestList)
+	}
+	if resilienceList, _ := reloaded.GetResilienceEntries(); len(resilienceList) != 1 || resilienceList[0].BotId != "b1" || resilienceList[0].Sensor.Message != "20" {
+		t.Errorf("got resilience entries %+v", resilienceList)
+	}
+	if deadList, _ := reloaded.GetDeadLetters(); len(deadList) != 1 || deadList[0].Attempts != 3 {
+		t.Errorf("got dead letters %+v", deadList)
+	}
+	if topicList, _ := reloaded.GetTopics(); len(topicList) != 1 || topicList[0].Name != "temperature" {
+		t.Errorf("got topics %+v", topicList)
+	}
+	if historyList, _ := reloaded.GetHistory("temperature", 0, 10, 0); len(historyList) != 2 || historyList[0].Offset != 2 {
+		t.Errorf("got history %+v", historyList)
+	}
+	if retainedList, _ := reloaded.GetRetained(); len(retainedList) != 1 || retainedList[0].CurrentSector != "A" {
+		t.Errorf("got retained %+v", retainedList)
+	}
+}
+
+func TestFileRepositoryTornLine(t *testing.T) {
+	dir := t.TempDir()
+	repo := newTestFileRepository(t, dir)
+	repo.CreateTables()
+
+	repo.AddBot(Bot{Id: "b1", Topic: "temperature"})
+	repo.AddHistoryEntry(Sensor{Id: "s1", Type: "temperature", MessageId: "m1", Offset: 1})
+
+	// a crash in the middle of an append leaves a partial line at the end
+	botsPath := filepath.Join(dir, botsFile)
+	segmentPath := repo.segmentOf("temperature")
+	for _, path := range []string{botsPath, segmentPath} {
+		file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		file.Write([]byte(`{"put":[{"id":"b2","to`))
+		file.Close()
+	}
+
+	reloaded := newTestFileRepository(t, dir)
+	if botsList, _ := reloaded.GetBots(); len(botsList) != 1 || botsList[0].Id != "b1" {
+		t.Errorf("got bots %+v after torn line", botsList)
+	}
+	if historyList, _ := reloaded.GetHistory("temperature", 0, 10, 0); len(historyList) != 1 {
+		t.Errorf("got history %+v after torn line", historyList)
+	}
+	for _, path := range []string{botsPath, segmentPath} {
+		data, _ := ioutil.ReadFile(path)
+		if len(data) == 0 || data[len(data)-1] != '\n' {
+			t.Errorf("partial line was not dropped from %s : %q", path, data)
+		}
+	}
+
+	// appends after recovery start on a line of their own
+	reloaded.AddBot(Bot{Id: "b2", Topic: "humidity"})
+	reloaded.AddHistoryEntry(Sensor{Id: "s1", Type: "temperature", MessageId: "m2", Offset: 2})
+	again := newTestFileRepository(t, dir)
+	if botsList, _ := again.GetBots(); len(botsList) != 2 {
+		t.Errorf("got bots %+v", botsList)
+	}
+	if historyList, _ := again.GetHistory("temperature", 0, 10, 0); len(historyList) != 2 {
+		t.Errorf("got history %+v", historyList)
+	}
+}
+
+func TestFileRepositoryCorruptLine(t *testing.T) {
+	dir := t.TempDir()
+	repo := newTestFileRepository(t, dir)
+	repo.CreateTables()
+
+	repo.AddBot(Bot{Id: "b1", Topic: "temperature"})
+	repo.AddBot(Bot{Id: "b2", Topic: "humidity"})
+
+	// a bad line followed by acknowledged ones cannot be dropped
+	path := filepath.Join(dir, botsFile)
+	data, _ := ioutil.ReadFile(path)
+	data[1] = '#'
+	ioutil.WriteFile(path, data, 0644)
+
+	if _, err := NewFileRepository(dir); err == nil {
+		t.Error("repository with a corrupt line in the middle was loaded")
+	}
+}
+
+func TestFileRepositoryFailedWrite(t *testing.T) {
+	repo := newTestFileRepository(t, t.TempDir())
+	repo.CreateTables()
+	repo.AddBot(Bot{Id: "b1", Topic: "temperature"})
+
+	repo.botsTable.journal.Close()
+	if err := repo.AddBot(Bot{Id: "b2", Topic: "humidity"}); err == nil {
+		t.Fatal("write to a closed journal succeeded")
+	}
+	if botsList, _ := repo.GetBots(); len(botsList) != 1 {
+		t.Errorf("memory changed by a failed write : %+v", botsList)
+	}
+}
+
+func TestFileRepositoryCompaction(t *testing.T) {
+	dir := t.TempDir()
+	repo := newTestFileRepository(t, dir)
+	repo.CreateTables()
+
+	repo.AddTopic(Topic{Name: "temperature"})
+	for i := 0; i < minCompactLines; i++ {
+		repo.AddTopic(Topic{Name: "humidity"})
+		repo.RemoveTopic("humidity")
+	}
+
+	data, _ := ioutil.ReadFile(filepath.Join(dir, topicsFile))
+	if lines := bytes.Count(data, []byte("\n")); lines >= minCompactLines {
+		t.Errorf("journal has %d lines, it was not compacted", lines)
+	}
+
+	repo.AddTopic(Topic{Name: "motion"})
+	if topicList, _ := newTestFileRepository(t, dir).GetTopics(); len(topicList) != 2 {
+		t.Errorf("got topics %+v after compaction", topicList)
+	}
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"github.com/lithammer/shortuuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"log"
+	"net"
+	"sync"
+)
+
+// gRPC front-end of the broker, every call does the same work of its REST handler
+type grpcServer struct {
+	UnimplementedWBMQServer
+}
+
+// serves gRPC API on address, alongside REST handlers
+func serveGRPC(address string) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	server := grpc.NewServer()
+	RegisterWBMQServer(server, &grpcServer{})
+	log.Fatal(server.Serve(listener))
+}
+
+func (server *grpcServer) SpawnBot(ctx context.Context, spec *BotSpec) (*BotSpec, error) {
+	newBot := botFromSpec(spec)
+	if newBot.Id == "" {
+		newBot.Id = shortuuid.New()
+	}
+
+	if err := validateBot(newBot); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err := addBot(newBot); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return specFromBot(newBot), nil
+}
+
+func (server *grpcServer) UnsubscribeBot(ctx context.Context, spec *BotSpec) (*BotSpec, error) {
+	removeBot(botFromSpec(spec))
+	return &BotSpec{}, nil
+}
+
+func (server *grpcServer) PublishSensor(ctx context.Context, request *SensorRequest) (*SensorRequest, error) {
+	var newSensor Sensor
+	newSensor.Id = request.GetId()
+	newSensor.Message = request.GetMsg()
+	newSensor.CurrentSector = request.GetCurrentSector()
+	newSensor.Type = request.GetType()
+	newSensor.Pbrtx = request.GetPbrtx()
+	newSensor.MessageId = request.GetMsgId()
+	newSensor.Seq = request.GetSeq()
+	newSensor.Payload = payloadFromProto(request.GetPayload())
+
+	if err := validateSensor(newSensor); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	ackSensor, err := acceptSensor(newSensor)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &SensorRequest{
+		Id:            ackSensor.Id,
+		Msg:           ackSensor.Message,
+		CurrentSector: ackSensor.CurrentSector,
+		Type:          ackSensor.Type,
+		Pbrtx:         ackSensor.Pbrtx,
+		MsgId:         ackSensor.MessageId,
+		Seq:           ackSensor.Seq,
+		Payload:       request.GetPayload(),
+	}, nil
+}
+
+func (server *grpcServer) Status(ctx context.Context, request *StatusRequest) (*StatusReply, error) {
+	pingNow := currentPing()
+	return &StatusReply{
+		Status:    pingNow.CtxStatus,
+		Totbot:    int32(pingNow.TotBot),
+		Totsens:   int32(pingNow.TotSens),
+		Timestamp: timestamppb.New(pingNow.Timestamp),
+	}, nil
+}
+
+func (server *grpcServer) Stats(ctx context.Context, request *StatsRequest) (*StatsReply, error) {
+	return &StatsReply{Timelist: takeTimes().TimesMeasurementsGC}, nil
+}
+
+// first request registers the bot, following ones ack its deliveries
+func (server *grpcServer) Subscribe(subscription WBMQ_SubscribeServer) error {
+
+	first, err := subscription.Recv()
+	if err != nil {
+		return err
+	}
+	if first.GetRegister() == nil {
+		return status.Error(codes.InvalidArgument, "first request must register a bot")
+	}
+
+	// grpc streams support one concurrent sender only, while many publishers may deliver to the same bot
+	var sendLock sync.Mutex
+	stream := newBotStream(func(payload map[string]interface{}) error {
+		sendLock.Lock()
+		defer sendLock.Unlock()
+		return subscription.Send(&SubscribeEvent{Event: &SubscribeEvent_Delivery{Delivery: deliveryFromPayload(payload)}})
+	})
+	defer close(stream.closed)
+
+	myBot, err := eb.registerStream(botFromSpec(first.GetRegister()), grpcMode, stream)
+	if err != nil {
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+	defer eb.unregisterStream(myBot.Id, stream)
+
+	sendLock.Lock()
+	err = subscription.Send(&SubscribeEvent{Event: &SubscribeEvent_Registered{Registered: specFromBot(myBot)}})
+	sendLock.Unlock()
+	if err != nil {
+		return err
+	}
+
+	for {
+		request, err := subscription.Recv()
+		if err != nil {
+			return err
+		}
+		if ack := request.GetAck(); ack != nil {
+			stream.signalAck(ack.GetMsgId())
+		}
+	}
+}
+
+func botFromSpec(spec *BotSpec) Bot {
+	var bot Bot
+	bot.Id = spec.GetId()
+	bot.CurrentSector = spec.GetCurrentSector()
+	bot.Topic = spec.GetTopic()
+	bot.IpAddress = spec.GetIpaddr()
+	bot.CallbackURL = spec.GetCallbackUrl()
+	bot.CallbackHeaders = spec.GetCallbackHeaders()
+	bot.Mode = spec.GetMode()
+	for _, subscription := range spec.GetSubscriptions() {
+		bot.Subscriptions = append(bot.Subscriptions, Subscription{
+			Topic:   subscription.GetTopic(),
+			Sectors: subscription.GetSectors(),
+			Scope:   subscription.GetScope(),
+			Routing: subscription.GetRouting(),
+			Filter:  subscription.GetFilter(),
+		})
+	}
+	return bot
+}
+
+func specFromBot(bot Bot) *BotSpec {
+	subscriptions := []*BotSubscription{}
+	for _, subscription := range bot.Subscriptions {
+		subscriptions = append(subscriptions, &BotSubscription{
+			Topic:   subscription.Topic,
+			Sectors: subscription.Sectors,
+			Scope:   subscription.Scope,
+			Routing: subscription.Routing,
+			Filter:  subscription.Filter,
+		})
+	}
+	return &BotSpec{
+		Subscriptions:   subscriptions,
+		Id:              bot.Id,
+		CurrentSector:   bot.CurrentSector,
+		Topic:           bot.Topic,
+		Ipaddr:          bot.IpAddress,
+		CallbackUrl:     bot.CallbackURL,
+		CallbackHeaders: bot.redacted().CallbackHeaders,
+		Mode:            bot.Mode,
+	}
+}
+
+// converts the json delivery payload built by deliveryPayload
+func deliveryFromPayload(payload map[string]interface{}) *Delivery {
+	delivery := &Delivery{}
+	delivery.Msg, _ = payload["msg"].(string)
+	delivery.MsgId, _ = payload["msg_id"].(string)
+	delivery.Seq, _ = payload["seq"].(int64)
+	delivery.Redelivery, _ = payload["redelivery"].(bool)
+	delivery.BotId, _ = payload["botId"].(string)
+	delivery.BotCs, _ = payload["bot_cs"].(string)
+	delivery.Sensor, _ = payload["sensor"].(string)
+	delivery.SensorCs, _ = payload["sensor_cs"].(string)
+	delivery.Topic, _ = payload["topic"].(string)
+	delivery.Retained, _ = payload["retained"].(bool)
+	delivery.Offset, _ = payload["offset"].(int64)
+	if sensorPayload, ok := payload["payload"].(*SensorPayload); ok {
+		delivery.Payload = payloadToProto(sensorPayload)
+	}
+	return delivery
+}
+
+func payloadFromProto(structured *StructuredPayload) *SensorPayload {
+	if structured == nil {
+		return nil
+	}
+
+	payload := &SensorPayload{Unit: structured.GetUnit()}
+	if structured.GetValue() != nil {
+		payload.Value = structured.GetValue().AsInterface()
+	}
+	if structured.GetTimestamp() != nil {
+		timestamp := structured.GetTimestamp().AsTime()
+		payload.Timestamp = &timestamp
+	}
+	if structured.GetAttributes() != nil {
+		payload.Attributes = structured.GetAttributes().AsMap()
+	}
+	return payload
+}
+
+// converts a structured payload, a value or attributes protobuf cannot represent are left out
+func payloadToProto(payload *SensorPayload) *StructuredPayload {
+	structured := &StructuredPayload{Unit: payload.Unit}
+	if payload.Value != nil {
+		structured.Value, _ = structpb.NewValue(payload.Value)
+	}
+	if payload.Timestamp != nil {
+		structured.Timestamp = timestamppb.New(*payload.Timestamp)
+	}
+	if payload.Attributes != nil {
+		structured.Attributes, _ = structpb.NewStruct(payload.Attributes)
+	}
+	return structured
+}
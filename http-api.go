@@ -2,13 +2,16 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/gorilla/mux"
 	"github.com/lithammer/shortuuid"
 	"log"
+	"math"
 	"net/http"
 	"os"
+	"runtime"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -32,6 +35,45 @@ type Bot struct {
 	CurrentSector string `json:"current_sector"`
 	Topic         string `json:"topic"`
 	IpAddress     string `json:"ipaddr"`
+
+	// topics bot is subscribed to besides Topic, each one optionally scoped to some sectors
+	Subscriptions []Subscription `json:"subscriptions,omitempty"`
+
+	// full url where notifications are POSTed (http://ipaddr:5001/ if missing) and headers added to them
+	CallbackURL     string            `json:"callback_url,omitempty"`
+	CallbackHeaders map[string]string `json:"callback_headers,omitempty"`
+
+	// "pull" for bots which fetch their messages from the broker, "push" (default) for bots notified by it,
+	// "websocket", "grpc" and "mqtt" are set by the broker for bots subscribed through /ws, Subscribe rpc and MQTT
+	Mode string `json:"mode,omitempty"`
+}
+
+// value callback headers have in responses, they may hold credentials of the bot
+const redactedHeader = "<redacted>"
+
+// returns bot as it is sent back in responses, with the values of its callback headers hidden
+func (bot Bot) redacted() Bot {
+	if len(bot.CallbackHeaders) == 0 {
+		return bot
+	}
+	headers := map[string]string{}
+	for header := range bot.CallbackHeaders {
+		headers[header] = redactedHeader
+	}
+	bot.CallbackHeaders = headers
+	return bot
+}
+
+// subscription of a bot to a topic, messages of every sector are routed as per routing mode if Sectors is empty.
+// Scope widens Sectors, or current sector of bot, to adjacent sectors, aisles or zones of warehouse layout.
+// Routing is "global" or "sector", routing mode of topic if missing. Filter, if any, is the expression
+// messages must satisfy to be sent to bot (see contentFilter)
+type Subscription struct {
+	Topic   string   `json:"topic"`
+	Sectors []string `json:"sectors,omitempty"`
+	Scope   string   `json:"scope,omitempty"`
+	Routing string   `json:"routing,omitempty"`
+	Filter  string   `json:"filter,omitempty"`
 }
 
 // Sensor
@@ -41,20 +83,105 @@ type Sensor struct {
 	CurrentSector string `json:"current_sector"`
 	Type          string `json:"type"`
 	Pbrtx         bool   `json:"pbrtx"`
+	MessageId     string `json:"msg_id"` // assigned by broker, unique for every publish
+	Seq           int64  `json:"seq"`    // assigned by broker, increasing with publish order
+
+	Payload *SensorPayload `json:"payload,omitempty"` // optional structured reading, besides msg
+
+	Offset   int64 `json:"offset,omitempty"`   // assigned by broker, position of message in the log of its topic
+	Retained bool  `json:"retained,omitempty"` // set by broker on the last message of topic and sector sent to a new subscriber
+
+	CopyOf string `json:"copy_of,omitempty"` // set by broker on a copy sent again to a single bot : msg_id bots get, msg_id keys the copy
+}
+
+// returns msg_id bots get for sensor message, the one of the original message for a copy
+func (sensor Sensor) deliveredId() string {
+	if sensor.CopyOf != "" {
+		return sensor.CopyOf
+	}
+	return sensor.MessageId
+}
+
+// returns a copy of sensor message to send again to a single bot. It keeps msg_id bots get, while the
+// copy has its own publish request, which does not collide with the one of the message still in flight
+func (sensor Sensor) copy() Sensor {
+	sensor.CopyOf = sensor.deliveredId()
+	sensor.MessageId = shortuuid.New()
+	return sensor
+}
+
+// structured reading of a sensor: value is a number, a string or a boolean, attributes are free
+type SensorPayload struct {
+	Value      interface{}            `json:"value,omitempty"`
+	Unit       string                 `json:"unit,omitempty"`
+	Timestamp  *time.Time             `json:"timestamp,omitempty"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+}
+
+// retry policy of the broker (empty topic) or of a single topic
+type TopicRetryPolicy struct {
+	Topic  string      `json:"topic"`
+	Policy RetryPolicy `json:"policy"`
+}
+
+// routing mode of the broker (empty topic) or of a single topic
+type TopicRouting struct {
+	Topic string `json:"topic"`
+	Mode  string `json:"mode"`
+}
+
+// routing modes in use by the broker
+type RoutingModes struct {
+	Default string            `json:"default"`
+	Topics  map[string]string `json:"topics"`
+}
+
+// retry policies in use by the broker
+type RetryPolicies struct {
+	Default RetryPolicy            `json:"default"`
+	Topics  map[string]RetryPolicy `json:"topics"`
+}
+
+// new position of a moving bot
+type BotLocation struct {
+	CurrentSector string `json:"current_sector"`
+}
+
+// replay asked by a bot, from an offset of the log of topic or from a time. Topic may be a filter with
+// wildcards when replaying from a time, since offsets only make sense in the log of one topic
+type BotReplay struct {
+	Topic      string     `json:"topic"`
+	FromOffset int64      `json:"from_offset,omitempty"`
+	From       *time.Time `json:"from,omitempty"`
+	Limit      int        `json:"limit,omitempty"`
+	Messages   int        `json:"messages"` // in the reply, number of messages replayed
+}
+
+// ack of a pull bot
+type PullAck struct {
+	MessageIds []string `json:"msg_ids"`
 }
 
 //resilience entry
 type resilienceEntry struct {
-	Id      string `json:"id"`
-	Message string `json:"message"`
+	Id        string `json:"id"`
+	Message   string `json:"message"`
+	MessageId string `json:"msg_id"`
+	BotId     string `json:"bot"`
+	Sensor    Sensor `json:"sensor"` // whole request, so a pull bot can get the message once request is removed
 }
 
 var bots []Bot
-var topics []string
 var contextLock = false
-var dynamoDBSession *dynamodb.DynamoDB = nil
-var sensorRequest sync.WaitGroup
+var storeBackend = "dynamo"
+var publishWorkers = runtime.NumCPU() // publishers only route messages and start deliveries, they do not wait for acks
+var queueSize = 1024
+var maxInFlight = 16384 // deliveries to bots in progress at once, each one may retry for as long as its retry policy
+var grpcAddress = ":5002"
+var mqttAddress = ":1883"
+var layoutFile = ""
 var resilienceLock sync.WaitGroup
+var botsLock sync.RWMutex // protects bots, held across every change to a bot so changes never interleave
 var testPack TestPack
 var timesLock sync.RWMutex
 
@@ -62,19 +189,47 @@ func main() {
 	router := mux.NewRouter()
 
 	checkCli()
-	tablesNumber, err := ExistingTables()
+	if layoutFile != "" {
+		layout, err := loadLayout(layoutFile)
+		if err != nil {
+			panic(err)
+		}
+		warehouse = layout
+	}
+	eb = NewBroker(newRepository(storeBackend), queueSize, maxInFlight)
+	if contextLock == true {
+		//broker routing unless another one was set at runtime, which LoadRouting restores
+		eb.routing = sectorRouting
+	}
+
+	tablesNumber, err := eb.repo.ExistingTables()
 	if err != nil {
 		panic(err)
 	}
-	if tablesNumber == 0 {
-		//create new tables
-		createTables()
-		time.Sleep(10 * time.Second)
+	if tablesNumber < len(repositoryTables) {
+		//create missing tables
+		if err := eb.repo.CreateTables(); err != nil {
+			panic(err)
+		}
+	}
+
+	initTopics(tablesNumber < len(repositoryTables))
+	if err := eb.LoadRetained(); err != nil {
+		panic(err)
+	}
+	if err := eb.LoadRetryPolicies(); err != nil {
+		panic(err)
+	}
+	if err := eb.LoadRouting(); err != nil {
+		panic(err)
+	}
+	if err := eb.LoadSeq(); err != nil {
+		panic(err)
 	}
 
 	checkDynamoBotsCache()
 
-	fmt.Println("System started working \n")
+	fmt.Println("System started working")
 
 	//get lock to make sure no other function works on db in this moment, to get a copy of system's pre-crash state
 	resilienceLock.Add(1)
@@ -84,8 +239,6 @@ func main() {
 
 	fmt.Println("End of waiting for checkresilience to read from DB")
 
-	initTopics()
-
 	router.HandleFunc("/stats", getTimes).Methods("GET")
 	router.HandleFunc("/status", heartBeatMonitoring).Methods("GET")
 
@@ -94,92 +247,157 @@ func main() {
 
 	router.HandleFunc("/sensor", spawnSensor).Methods("POST")
 
-	//standard line that listen to any request
-	go func() {
-		log.Fatal(http.ListenAndServe(":5000", router))
-	}()
+	router.HandleFunc("/layout", getLayout).Methods("GET")
 
-	//Main loop on sensorsRequest
-	for {
+	router.HandleFunc("/topics", getTopics).Methods("GET")
+	router.HandleFunc("/topics", createTopic).Methods("POST")
+	router.HandleFunc("/topics/{name:.+}", getTopic).Methods("GET")
+	router.HandleFunc("/topics/{name:.+}", updateTopic).Methods("PUT")
+	router.HandleFunc("/topics/{name:.+}", deleteTopic).Methods("DELETE")
 
-		if len(eb.sensorsRequest) > 0 {
+	router.HandleFunc("/history", getHistory).Methods("GET")
 
-			request := eb.sensorsRequest[0]
-			sensorRequest.Add(1)
+	router.HandleFunc("/ws", websocketSubscribe).Methods("GET")
+	router.HandleFunc("/stream", streamMessages).Methods("GET")
 
-			go func(request Sensor) {
+	router.HandleFunc("/bot/{id}/location", updateLocation).Methods("POST")
+	router.HandleFunc("/bot/{id}/replay", replayMessages).Methods("POST")
 
-				myRequest := request
-				sensorRequest.Done()
-				eb.Publish(myRequest)
+	router.HandleFunc("/bot/{id}/subscriptions", getSubscriptions).Methods("GET")
+	router.HandleFunc("/bot/{id}/subscriptions", addSubscription).Methods("POST")
+	router.HandleFunc("/bot/{id}/subscriptions/{topic:.+}", removeSubscription).Methods("DELETE")
 
-			}(request)
+	router.HandleFunc("/bot/{id}/messages", pullMessages).Methods("GET")
+	router.HandleFunc("/bot/{id}/ack", ackMessages).Methods("POST")
 
-			sensorRequest.Wait()
-			eb.lockQueue.Lock()
+	router.HandleFunc("/routing", getRoutingModes).Methods("GET")
+	router.HandleFunc("/routing", setRoutingMode).Methods("POST")
+	router.HandleFunc("/routing/{topic:.+}", removeRoutingMode).Methods("DELETE")
 
-			eb.sensorsRequest = append(eb.sensorsRequest[:0], eb.sensorsRequest[1:]...)
+	router.HandleFunc("/retryPolicy", getRetryPolicies).Methods("GET")
+	router.HandleFunc("/retryPolicy", setRetryPolicy).Methods("POST")
+	router.HandleFunc("/retryPolicy/{topic:.+}", removeRetryPolicy).Methods("DELETE")
 
-			eb.lockQueue.Unlock()
+	router.HandleFunc("/deadLetters", getDeadLetters).Methods("GET")
+	router.HandleFunc("/deadLetters", purgeDeadLetters).Methods("DELETE")
+	router.HandleFunc("/deadLetters/{id}", getDeadLetter).Methods("GET")
+	router.HandleFunc("/deadLetters/{id}", purgeDeadLetter).Methods("DELETE")
+	router.HandleFunc("/deadLetters/{id}/requeue", requeueDeadLetter).Methods("POST")
 
-		}
+	//workers serving sensorsRequest queue
+	eb.StartPublishers(publishWorkers)
+	//removes messages past retention of their topic
+	eb.StartRetention(retentionInterval)
 
-	}
+	go serveGRPC(grpcAddress)
+	go serveMQTT(mqttAddress)
+
+	//standard line that listen to any request
+	log.Fatal(http.ListenAndServe(":5000", router))
 }
 
-//STATIC OBJECT IN THE SYSTEM
-func initTopics() {
-	topics = make([]string, 0)
-	topics = append(topics,
-		"temperature",
-		"humidity",
-		"motion")
+//loads topic registry, a registry just created starts with the default topics
+func initTopics(newRegistry bool) {
+	if err := eb.LoadTopics(); err != nil {
+		panic(err)
+	}
+	if !newRegistry || len(eb.Topics()) > 0 {
+		return
+	}
+	for _, name := range defaultTopics {
+		if _, err := eb.RegisterTopic(Topic{Name: name}); err != nil {
+			panic(err)
+		}
+	}
 }
 
-//check for first element inserted by command-line to create a context/non context aware environment
+//check for elements inserted by command-line : "ctx" creates a context aware environment (non context aware if missing),
+//that is routing mode is sector instead of global until changed through /routing,
+//"store=<backend>" selects the storage backend (dynamo if missing), "workers=<n>" sets the number of publish workers
+//"queue=<n>" the number of publish requests which can wait for a worker before sensors are blocked
+//and "inflight=<n>" the number of deliveries to bots in progress before workers are blocked,
+//"grpc=<address>" and "mqtt=<address>" set where gRPC API and MQTT listener listen (:5002 and :1883 if missing),
+//"layout=<file>" loads the json warehouse layout used by subscription scopes
 func checkCli() {
-	if len(os.Args) > 1 {
-		arg := os.Args[1]
-		if arg == "ctx" {
+	for _, arg := range os.Args[1:] {
+		switch {
+		case arg == "ctx":
 			contextLock = true
-		} else {
+		case strings.HasPrefix(arg, "store="):
+			storeBackend = strings.TrimPrefix(arg, "store=")
+		case strings.HasPrefix(arg, "workers="):
+			publishWorkers = positiveCliValue(arg, "workers=")
+		case strings.HasPrefix(arg, "queue="):
+			queueSize = positiveCliValue(arg, "queue=")
+		case strings.HasPrefix(arg, "inflight="):
+			maxInFlight = positiveCliValue(arg, "inflight=")
+		case strings.HasPrefix(arg, "grpc="):
+			grpcAddress = strings.TrimPrefix(arg, "grpc=")
+		case strings.HasPrefix(arg, "mqtt="):
+			mqttAddress = strings.TrimPrefix(arg, "mqtt=")
+		case strings.HasPrefix(arg, "layout="):
+			layoutFile = strings.TrimPrefix(arg, "layout=")
+		default:
 			panic("Wrong argument inserted!")
 		}
 	}
 }
 
+// parses the integer value of a "name=<n>" command-line argument
+func positiveCliValue(arg string, prefix string) int {
+	value, err := strconv.Atoi(strings.TrimPrefix(arg, prefix))
+	if err != nil || value <= 0 {
+		panic("Wrong argument inserted! " + arg + " must be a positive number")
+	}
+	return value
+}
+
 // routine that returns service time for every pub served requests (time requests arrive - time all bots receive the message)
 func getTimes(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(takeTimes())
+}
+
+// returns service times measured since last call
+func takeTimes() TestPack {
 	snap := testPack
 	timesLock.Lock()
 	testPack.TimesMeasurementsGC = testPack.TimesMeasurementsGC[:0]
 	testPack.TimesMeasurementsGC = []float64{}
 	timesLock.Unlock()
-	json.NewEncoder(w).Encode(snap)
+	return snap
 }
 
 // function to ping the application
 func heartBeatMonitoring(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(currentPing())
+}
+
+func currentPing() Ping {
 	var pingNow Ping
 	pingNow.Timestamp = time.Now()
-	if contextLock == true {
+	if defaultRouting, _ := eb.RoutingModes(); defaultRouting == sectorRouting {
 		pingNow.CtxStatus = "alivectx"
 	} else {
 		pingNow.CtxStatus = "alive"
 	}
+	botsLock.RLock()
 	pingNow.TotBot = len(bots)
-	json.NewEncoder(w).Encode(pingNow)
+	botsLock.RUnlock()
+	return pingNow
 }
 
 //retrieve bots state from DB if any robot is found and subscribe them to their topics
 func checkDynamoBotsCache() {
-	res, err := GetDBBots()
+	res, err := eb.repo.GetBots()
 
 	if err != nil {
 		panic(err)
 	}
+	botsLock.Lock()
+	defer botsLock.Unlock()
+
 	for _, i := range res {
 		bots = append(bots, i)
 		eb.Subscribe(i)
@@ -193,32 +411,88 @@ func spawnSensor(w http.ResponseWriter, r *http.Request) {
 
 	json.NewDecoder(r.Body).Decode(&newSensor)
 
+	if err := validateSensor(newSensor); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ackSensor, err := acceptSensor(newSensor)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ackSensor)
+}
+
+//checks a sensor request: its topic must be registered and its payload valid against the topic schema
+func validateSensor(newSensor Sensor) error {
+	if err := newSensor.Payload.validate(); err != nil {
+		return err
+	}
+	return eb.checkTopicOf(newSensor)
+}
+
+//stores sensor request and queues it for publish, returns the ack for the sensor
+func acceptSensor(newSensor Sensor) (Sensor, error) {
+
 	//check if sensor already in system
 	if newSensor.Id == "" {
 		newSensor.Id = shortuuid.New()
 	}
+	newSensor.Offset = 0
+	newSensor.Retained = false
 
 	var msg = newSensor.Message
 	var ack = "Ack on message : " + msg + " on sensor :" + newSensor.Id
 
 	//check if message is a new message or a retransmission
-	//if PiggyBagRetransmission is true it means that message had already been transmitted so do nothing but ack
+	//if PiggyBagRetransmission is true message may have already been received, so it is served only if broker
+	//has no trace of it, otherwise a lost original would be silently dropped
+	duplicate := false
 	if newSensor.Pbrtx {
 
-		//TODO funzione ricerca sensor request if found nothing, else eseguo servizio
+		var err error
+		duplicate, err = eb.IsPending(newSensor)
+		if err != nil {
+			return newSensor, err
+		}
+	}
+
+	if duplicate {
+
+		fmt.Println("Duplicate message : " + msg + " on sensor :" + newSensor.Id)
+
+	} else {
+
+		//a retransmission keeps the id of the original, so bots can recognize it if they already got it,
+		//while seq is always given by the broker. The original got its msg_id in an ack once it was logged,
+		//so the retransmission is neither logged nor retained again
+		retransmission := newSensor.Pbrtx && newSensor.MessageId != ""
+		if retransmission {
+			newSensor.Seq = eb.nextSeq()
+		} else {
+			eb.AssignMessageId(&newSensor)
 
-	} else if !newSensor.Pbrtx {
+			if err := eb.appendToLog(&newSensor); err != nil {
+				return newSensor, err
+			}
+		}
 
-		AddDBSensorRequest(newSensor)
+		if err := eb.repo.AddSensorRequest(newSensor); err != nil {
+			return newSensor, err
+		}
+		if !retransmission {
+			if err := eb.setRetained(newSensor); err != nil {
+				return newSensor, err
+			}
+		}
 		//TODO campo check sens request settato a true se tutte le res entries scritte su db
-		eb.lockQueue.Lock()
-		eb.sensorsRequest = append(eb.sensorsRequest, newSensor)
-		eb.lockQueue.Unlock()
+		eb.Enqueue(newSensor)
 
 	}
 	newSensor.Message = ack
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(newSensor)
+	return newSensor, nil
 }
 
 //spawns a new bot with given values
@@ -229,26 +503,95 @@ func spawnBot(w http.ResponseWriter, r *http.Request) {
 		newBot.Id = shortuuid.New()
 	}
 
-	bots = append(bots, newBot)
+	if err := validateBot(newBot); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	AddDBBot(newBot)
-	eb.Subscribe(newBot)
+	if err := addBot(newBot); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(newBot)
+	json.NewEncoder(w).Encode(newBot.redacted())
+}
+
+//checks a bot registering through /bot
+func validateBot(newBot Bot) error {
+	if newBot.Mode != "" && newBot.Mode != pushMode && newBot.Mode != pullMode {
+		return errors.New("mode must be " + pushMode + " or " + pullMode)
+	}
+	if err := newBot.validateSubscriptions(); err != nil {
+		return err
+	}
+	return newBot.validateCallback()
+}
+
+//messages a change of bot leaves to send to it, collected while botsLock is held and sent once it is released,
+//since sending them stores their requests and may wait for room among deliveries in flight
+type botBacklog struct {
+	bot      Bot
+	pulled   []resilienceEntry // messages it pulled without acking, if it does not pull anymore
+	retained []Sensor          // retained messages its new subscriptions get
+}
+
+func (backlog botBacklog) send() error {
+	if err := eb.handOverPulled(backlog.bot, backlog.pulled); err != nil {
+		return err
+	}
+	return eb.deliverRetained(backlog.bot, backlog.retained)
+}
+
+//stores bot, subscribes it to its topic and sends it the retained messages of its subscriptions
+func addBot(newBot Bot) error {
+	backlog, err := storeBot(newBot)
+	if err != nil {
+		return err
+	}
+	return backlog.send()
+}
+
+func storeBot(newBot Bot) (botBacklog, error) {
+	botsLock.Lock()
+	defer botsLock.Unlock()
+
+	//a bot registering again is updated, so it is listed once and messages it has still to ack follow it
+	if myBot := botById(newBot.Id); myBot.Id != "" {
+		return updateBot(myBot, newBot)
+	}
+	return storeNewBot(newBot)
+}
+
+//adds bot as addBot does, returning the messages to send to it. botsLock must be held
+func storeNewBot(newBot Bot) (botBacklog, error) {
+	if err := eb.repo.AddBot(newBot); err != nil {
+		return botBacklog{}, err
+	}
+	bots = append(bots, newBot)
+	eb.Subscribe(newBot)
+	return botBacklog{bot: newBot, retained: eb.retainedFor(Bot{}, newBot)}, nil
 }
 
 func checkResilience() {
 
-	resilience, err := GetResilienceEntries()
+	resilience, err := eb.repo.GetResilienceEntries()
 	if err != nil {
 		panic(err)
 	}
 
-	requestSlice, err1 := GetRequestEntries()
+	requestSlice, err1 := eb.repo.GetRequestEntries()
 	if err1 != nil {
-		panic(err)
+		panic(err1)
+	}
+
+	pullResilience := []resilienceEntry{}
+	for _, resilienceItem := range resilience {
+		if findBotbyId(resilienceItem.BotId).Mode == pullMode {
+			pullResilience = append(pullResilience, resilienceItem)
+		}
 	}
+	eb.markPulled(pullResilience)
 
 	//once i got the system's state before crash i can release lock for main to gon on and listen and serve new requests
 	//while i serve the older ones too
@@ -263,9 +606,7 @@ func checkResilience() {
 
 		go func(mySensor Sensor) {
 
-			var wg sync.WaitGroup
-
-			myRequestItem := requestItem
+			myRequestItem := mySensor
 			requestResilienceEntries := []resilienceEntry{}
 
 			var sensor Sensor
@@ -274,41 +615,40 @@ func checkResilience() {
 			sensor.Type = myRequestItem.Type
 			sensor.Pbrtx = myRequestItem.Pbrtx
 			sensor.CurrentSector = myRequestItem.CurrentSector
+			sensor.MessageId = myRequestItem.MessageId
+			sensor.Seq = myRequestItem.Seq
+			sensor.Payload = myRequestItem.Payload
+			sensor.Offset = myRequestItem.Offset
+			sensor.Retained = myRequestItem.Retained
 
 			//for every request creates the list of its own resilience entries
 			for _, resilienceItem := range resilience {
 
-				if resilienceItem.Message == sensor.Message && strings.Contains(resilienceItem.Id, sensor.Id) {
+				if resilienceItem.MessageId == sensor.MessageId && resilienceItem.Id == resilienceItem.BotId+sensor.Id {
 
 					requestResilienceEntries = append(requestResilienceEntries, resilienceItem)
 				}
 			}
 
-			if len(requestResilienceEntries) > 0 {
+			//retransmit the request to every entry
+			requestBots := []Bot{}
+			for _, resilienceItem := range requestResilienceEntries {
 
-				//retransmit the request to every entry
-				for _, resilienceItem := range requestResilienceEntries {
+				botId := resilienceItem.BotId
+				myBot := findBotbyId(botId)
 
-					myBot := findBotbyId(strings.ReplaceAll(resilienceItem.Id, myRequestItem.Id, ""))
+				//bot was removed without its messages going to dead letters, they can be requeued if it comes back
+				if myBot.Id == "" {
 
-					if myBot.Id == "" {
-
-						panic("NO BOT ASSOCIATED WITH THIS RESILIENCE ENTRY : SOMETHING WRONG ")
-
-					} else if myBot.Id != "" {
-
-						wg.Add(1)
-
-						go publishImplementation(myBot, sensor, &wg)
-					}
+					eb.deadLetter(Bot{Id: botId}, sensor, 0, errors.New("bot "+botId+" is not registered"))
+					continue
 
 				}
-
-				//awaits for all subroutines to end with an ack
-				wg.Wait()
+				requestBots = append(requestBots, myBot)
 			}
 
-			removePubRequest(sensor.Id, sensor.Message)
+			//awaits for all subroutines to end, with the same retry policy of a live publish
+			<-eb.notifyAndRemove(requestBots, sensor, true)
 
 			mainWg.Done()
 
@@ -320,6 +660,14 @@ func checkResilience() {
 }
 
 func findBotbyId(id string) Bot {
+	botsLock.RLock()
+	defer botsLock.RUnlock()
+
+	return botById(id)
+}
+
+//returns bot with id, an empty Bot if it is not registered, botsLock must be held
+func botById(id string) Bot {
 
 	for _, bot := range bots {
 
@@ -339,21 +687,604 @@ func unsubscribeBot(w http.ResponseWriter, r *http.Request) {
 	var newBot Bot
 	json.NewDecoder(r.Body).Decode(&newBot)
 
+	removeBot(newBot)
+
+	var newBotAsResponse Bot
+	newBotAsResponse.Topic = "null"
+	newBotAsResponse.Id = "null"
+	newBotAsResponse.CurrentSector = "null"
+	newBotAsResponse.IpAddress = "null"
+	w.Header().Set("Content-Type", "application/json")
+	// send back some ack
+	json.NewEncoder(w).Encode(newBotAsResponse)
+}
+
+//unsubscribes bot and forgets it, messages it has still to ack go to dead letters
+func removeBot(newBot Bot) {
+	newBot = forgetBot(newBot)
+	eb.deadLetterPending(newBot)
+}
+
+//unsubscribes bot and removes it from bots, returns it as the broker knew it
+func forgetBot(newBot Bot) Bot {
+	botsLock.Lock()
+	defer botsLock.Unlock()
+
+	//subscriptions of the bot are the ones the broker knows, not the ones in the request
+	if knownBot := botById(newBot.Id); knownBot.Id != "" {
+		newBot = knownBot
+	}
 	eb.Unsubscribe(newBot)
+	eb.forgetPuller(newBot.Id)
 	for k, bot := range bots {
 
 		if bot.Id == newBot.Id {
 
 			bots = append(bots[:k], bots[k+1:]...)
+			break
 		}
 	}
+	return newBot
+}
 
-	var newBotAsResponse Bot
-	newBotAsResponse.Topic = "null"
-	newBotAsResponse.Id = "null"
-	newBotAsResponse.CurrentSector = "null"
-	newBotAsResponse.IpAddress = "null"
+// moves a bot to another sector, its subscriptions follow it while messages already sent to it keep going
+func updateLocation(w http.ResponseWriter, r *http.Request) {
+	var location BotLocation
+	if err := json.NewDecoder(r.Body).Decode(&location); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if location.CurrentSector == "" {
+		http.Error(w, "current_sector is missing", http.StatusBadRequest)
+		return
+	}
+
+	myBot, err := moveBot(mux.Vars(r)["id"], location.CurrentSector)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if myBot.Id == "" {
+		http.Error(w, "bot not found", http.StatusNotFound)
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
-	// send back some ack
-	json.NewEncoder(w).Encode(newBotAsResponse)
+	json.NewEncoder(w).Encode(myBot.redacted())
+}
+
+// delivers again to a bot the messages of a topic log it gets by its subscriptions, from an offset or a time.
+// Messages are delivered after the reply, which tells how many they are
+func replayMessages(w http.ResponseWriter, r *http.Request) {
+	var replay BotReplay
+	if err := json.NewDecoder(r.Body).Decode(&replay); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validateTopicFilter(replay.Topic); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if (replay.FromOffset > 0) == (replay.From != nil) {
+		http.Error(w, "replay needs either from_offset or from", http.StatusBadRequest)
+		return
+	}
+	if replay.FromOffset < 0 || replay.Limit < 0 {
+		http.Error(w, "from_offset and limit cannot be negative", http.StatusBadRequest)
+		return
+	}
+
+	fromOffset := replay.FromOffset
+	if replay.From != nil {
+		fromOffset = seqAt(*replay.From)
+	} else if _, found := eb.GetTopic(replay.Topic); !found {
+		http.Error(w, "topic "+replay.Topic+" is not registered", http.StatusBadRequest)
+		return
+	}
+
+	myBot := findBotbyId(mux.Vars(r)["id"])
+	if myBot.Id == "" {
+		http.Error(w, "bot not found", http.StatusNotFound)
+		return
+	}
+
+	messages, err := eb.Replay(myBot, replay.Topic, fromOffset, replay.Limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	replay.Messages = messages
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(replay)
+}
+
+// returns topic subscriptions of a bot
+func getSubscriptions(w http.ResponseWriter, r *http.Request) {
+	myBot := findBotbyId(mux.Vars(r)["id"])
+	if myBot.Id == "" {
+		http.Error(w, "bot not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(myBot.topicSubscriptions())
+}
+
+// subscribes a bot to one more topic, or changes the sectors of a topic it is already subscribed to
+func addSubscription(w http.ResponseWriter, r *http.Request) {
+	var newSubscription Subscription
+	if err := json.NewDecoder(r.Body).Decode(&newSubscription); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := newSubscription.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	myBot, err := subscribeBot(mux.Vars(r)["id"], newSubscription)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if myBot.Id == "" {
+		http.Error(w, "bot not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(myBot.redacted())
+}
+
+// unsubscribes a bot from one of its topics, bot stays registered
+func removeSubscription(w http.ResponseWriter, r *http.Request) {
+	myBot, found, err := unsubscribeTopic(mux.Vars(r)["id"], mux.Vars(r)["topic"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if myBot.Id == "" || !found {
+		http.Error(w, "subscription not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(myBot.redacted())
+}
+
+//adds subscription to bot with botId, returns an empty Bot if it is not found
+func subscribeBot(botId string, subscription Subscription) (Bot, error) {
+	myBot, _, err := changeBot(botId, func(myBot Bot) (Bot, bool) {
+		return myBot.withSubscription(subscription), true
+	})
+	return myBot, err
+}
+
+//moves bot with botId to sector, returns an empty Bot if it is not found.
+//Bot is updated in place, so its resilience entries and deliveries in progress are not touched
+func moveBot(botId string, sector string) (Bot, error) {
+	myBot, _, err := changeBot(botId, func(myBot Bot) (Bot, bool) {
+		myBot.CurrentSector = sector
+		return myBot, true
+	})
+	return myBot, err
+}
+
+//removes subscription to topic of bot with botId, tells whether bot was subscribed to it
+func unsubscribeTopic(botId string, topic string) (Bot, bool, error) {
+	return changeBot(botId, func(myBot Bot) (Bot, bool) {
+		if myBot.Topic != topic && myBot.subscriptionTo(topic) == nil {
+			return myBot, false
+		}
+		return myBot.withoutSubscription(topic), true
+	})
+}
+
+//updates bot with botId to the version change returns, unless change tells it leaves bot as it is, then
+//sends bot its backlog once botsLock is released. Returns an empty Bot if it is not found
+func changeBot(botId string, change func(myBot Bot) (Bot, bool)) (Bot, bool, error) {
+	botsLock.Lock()
+
+	myBot := botById(botId)
+	if myBot.Id == "" {
+		botsLock.Unlock()
+		return myBot, false, nil
+	}
+	newBot, changed := change(myBot)
+	if !changed {
+		botsLock.Unlock()
+		return myBot, false, nil
+	}
+	backlog, err := updateBot(myBot, newBot)
+	botsLock.Unlock()
+
+	if err != nil {
+		return newBot, true, err
+	}
+	return newBot, true, backlog.send()
+}
+
+//stores new version of a bot and moves it to its new subscriptions, returning the retained messages they
+//get and the old ones did not, and the ones it pulled without acking if it does not pull anymore.
+//botsLock must be held since oldBot was found, so a bot removed in between is never stored again
+func updateBot(oldBot Bot, newBot Bot) (botBacklog, error) {
+	backlog := botBacklog{bot: newBot}
+	if err := eb.repo.AddBot(newBot); err != nil {
+		return backlog, err
+	}
+	for k, bot := range bots {
+		if bot.Id == newBot.Id {
+			bots[k] = newBot
+			break
+		}
+	}
+	eb.Resubscribe(oldBot, newBot)
+	if oldBot.Mode == pullMode && newBot.Mode != pullMode {
+		pulled, err := eb.repo.GetBotResilienceEntries(oldBot.Id)
+		if err != nil {
+			return backlog, err
+		}
+		backlog.pulled = pulled
+		eb.forgetPuller(oldBot.Id)
+	}
+	backlog.retained = eb.retainedFor(oldBot, newBot)
+	return backlog, nil
+}
+
+// returns registered topics
+func getTopics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(eb.Topics())
+}
+
+// registers a new topic, with the json schema of its payload if any
+func createTopic(w http.ResponseWriter, r *http.Request) {
+	var newTopic Topic
+	if err := json.NewDecoder(r.Body).Decode(&newTopic); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, err := newTopic.compile(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	created, err := eb.RegisterTopic(newTopic)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !created {
+		http.Error(w, "topic "+newTopic.Name+" already exists", http.StatusConflict)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(newTopic)
+}
+
+// describes a registered topic
+func getTopic(w http.ResponseWriter, r *http.Request) {
+	topic, found := eb.GetTopic(mux.Vars(r)["name"])
+	if !found {
+		http.Error(w, "topic not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(topic)
+}
+
+// changes description, schema or retention of a registered topic
+func updateTopic(w http.ResponseWriter, r *http.Request) {
+	var newTopic Topic
+	if err := json.NewDecoder(r.Body).Decode(&newTopic); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	newTopic.Name = mux.Vars(r)["name"]
+	if _, err := newTopic.compile(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	updated, err := eb.UpdateTopic(newTopic)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !updated {
+		http.Error(w, "topic not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newTopic)
+}
+
+// removes a topic from the registry, sensors cannot publish on it anymore
+func deleteTopic(w http.ResponseWriter, r *http.Request) {
+	removed, err := eb.RemoveTopic(mux.Vars(r)["name"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !removed {
+		http.Error(w, "topic not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// returns messages in the log of topic, which may be a filter with wildcards, optionally only the ones
+// sent from sector and published between from and to (RFC 3339 times), oldest first and at most limit of them
+func getHistory(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	topic := query.Get("topic")
+	if err := validateTopicFilter(topic); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	fromOffset, toOffset := int64(0), int64(math.MaxInt64)
+	if from := query.Get("from"); from != "" {
+		fromTime, err := time.Parse(time.RFC3339, from)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		fromOffset = seqAt(fromTime)
+	}
+	if to := query.Get("to"); to != "" {
+		toTime, err := time.Parse(time.RFC3339, to)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		toOffset = seqAt(toTime)
+	}
+
+	limit := 0
+	if value := query.Get("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit <= 0 {
+			http.Error(w, "limit must be a positive number", http.StatusBadRequest)
+			return
+		}
+	}
+
+	history, err := eb.History(topic, query.Get("sector"), fromOffset, toOffset, limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(history)
+}
+
+// returns broker routing mode and per topic overrides
+func getRoutingModes(w http.ResponseWriter, r *http.Request) {
+	var modes RoutingModes
+	modes.Default, modes.Topics = eb.RoutingModes()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(modes)
+}
+
+// sets the broker routing mode, or the one of a topic if given
+func setRoutingMode(w http.ResponseWriter, r *http.Request) {
+	var newMode TopicRouting
+	if err := json.NewDecoder(r.Body).Decode(&newMode); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := validateRouting(newMode.Mode); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := eb.SetRouting(newMode.Topic, newMode.Mode); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newMode)
+}
+
+// removes the routing mode of a topic, its messages go back to broker routing mode
+func removeRoutingMode(w http.ResponseWriter, r *http.Request) {
+	if err := eb.RemoveRouting(mux.Vars(r)["topic"]); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// returns broker retry policy and per topic overrides
+func getRetryPolicies(w http.ResponseWriter, r *http.Request) {
+	var policies RetryPolicies
+	policies.Default, policies.Topics = eb.RetryPolicies()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(policies)
+}
+
+// sets the broker retry policy, or the one of a topic if given
+func setRetryPolicy(w http.ResponseWriter, r *http.Request) {
+	var newPolicy TopicRetryPolicy
+	if err := json.NewDecoder(r.Body).Decode(&newPolicy); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := newPolicy.Policy.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := eb.SetRetryPolicy(newPolicy.Topic, newPolicy.Policy); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newPolicy)
+}
+
+// removes the retry policy of a topic, its messages go back to broker retry policy
+func removeRetryPolicy(w http.ResponseWriter, r *http.Request) {
+	topic := mux.Vars(r)["topic"]
+	if err := eb.RemoveRetryPolicy(topic); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// returns every message which bots never acked
+func getDeadLetters(w http.ResponseWriter, r *http.Request) {
+	deadList, err := eb.repo.GetDeadLetters()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(deadList)
+}
+
+// returns the dead letter with given id
+func getDeadLetter(w http.ResponseWriter, r *http.Request) {
+	letter, ok := findDeadLetter(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(letter)
+}
+
+// delivers again the dead letter with given id to its bot
+func requeueDeadLetter(w http.ResponseWriter, r *http.Request) {
+	letter, ok := findDeadLetter(w, r)
+	if !ok {
+		return
+	}
+
+	myBot := findBotbyId(letter.BotId)
+	if myBot.Id == "" {
+		http.Error(w, "bot "+letter.BotId+" is no longer subscribed", http.StatusConflict)
+		return
+	}
+
+	if err := eb.Requeue(letter, myBot); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(letter)
+}
+
+// removes the dead letter with given id
+func purgeDeadLetter(w http.ResponseWriter, r *http.Request) {
+	if err := eb.repo.RemoveDeadLetter(mux.Vars(r)["id"]); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// removes every dead letter
+func purgeDeadLetters(w http.ResponseWriter, r *http.Request) {
+	deadList, err := eb.repo.GetDeadLetters()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for _, letter := range deadList {
+		if err := eb.repo.RemoveDeadLetter(letter.Id); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// looks up dead letter from request path, answering with an error if it is not found
+func findDeadLetter(w http.ResponseWriter, r *http.Request) (DeadLetter, bool) {
+	letter, err := eb.repo.GetDeadLetter(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return letter, false
+	}
+	if letter.Id == "" {
+		http.Error(w, "dead letter not found", http.StatusNotFound)
+		return letter, false
+	}
+	return letter, true
+}
+
+// returns messages waiting for a pull bot, long-polling up to "wait" seconds (default 30) if there is none.
+// Messages are returned again until the bot acks them
+func pullMessages(w http.ResponseWriter, r *http.Request) {
+	myBot, ok := findPullBot(w, r)
+	if !ok {
+		return
+	}
+
+	wait := 30 * time.Second
+	if waitParam := r.URL.Query().Get("wait"); waitParam != "" {
+		seconds, err := strconv.Atoi(waitParam)
+		if err != nil || seconds < 0 {
+			http.Error(w, "wait must be a number of seconds", http.StatusBadRequest)
+			return
+		}
+		wait = time.Duration(seconds) * time.Second
+	}
+	if wait > maxPullWait {
+		wait = maxPullWait
+	}
+
+	max := 0
+	if maxParam := r.URL.Query().Get("max"); maxParam != "" {
+		var err error
+		max, err = strconv.Atoi(maxParam)
+		if err != nil || max < 0 {
+			http.Error(w, "max must be a positive number", http.StatusBadRequest)
+			return
+		}
+	}
+
+	messages, err := eb.Pull(myBot, max, wait, r.Context().Done())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(messages)
+}
+
+// removes messages acked by a pull bot
+func ackMessages(w http.ResponseWriter, r *http.Request) {
+	myBot, ok := findPullBot(w, r)
+	if !ok {
+		return
+	}
+
+	var ack PullAck
+	if err := json.NewDecoder(r.Body).Decode(&ack); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := eb.AckPulled(myBot, ack.MessageIds); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ack)
+}
+
+// looks up the pull bot from request path, answering with an error if it is not found
+func findPullBot(w http.ResponseWriter, r *http.Request) (Bot, bool) {
+	myBot := findBotbyId(mux.Vars(r)["id"])
+	if myBot.Id == "" {
+		http.Error(w, "bot not found", http.StatusNotFound)
+		return myBot, false
+	}
+	if myBot.Mode != pullMode {
+		http.Error(w, "bot is not registered in pull mode", http.StatusConflict)
+		return myBot, false
+	}
+	return myBot, true
 }
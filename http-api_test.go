@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// serves request on a router with the routes of main that tests use
+func serveTestRequest(method string, path string, body string) *httptest.ResponseRecorder {
+	router := mux.NewRouter()
+	router.HandleFunc("/sensor", spawnSensor).Methods("POST")
+	router.HandleFunc("/bot", spawnBot).Methods("POST")
+	router.HandleFunc("/unsubscribeBot", unsubscribeBot).Methods("POST")
+	router.HandleFunc("/deadLetters/{id}/requeue", requeueDeadLetter).Methods("POST")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+	return w
+}
+
+func TestCheckResilience(t *testing.T) {
+	newTestBroker(t)
+	callback := newTestCallback(t, 0)
+
+	// state left by a broker which crashed while delivering a message to a bot whose id contains the sensor id,
+	// and to a bot removed before the crash
+	eb.repo.AddBot(Bot{Id: "s1-watcher", Topic: "temperature", CallbackURL: callback.URL})
+	sensor := Sensor{Id: "s1", Type: "temperature", Message: "20"}
+	eb.AssignMessageId(&sensor)
+	eb.repo.AddSensorRequest(sensor)
+	eb.repo.WriteBotIdsAndMessage([]Bot{{Id: "s1-watcher"}, {Id: "gone"}}, sensor)
+
+	checkDynamoBotsCache()
+	resilienceLock.Add(1)
+	checkResilience()
+
+	select {
+	case payload := <-callback.notified:
+		if payload["botId"] != "s1-watcher" || payload["msg_id"] != sensor.MessageId || payload["redelivery"] != true {
+			t.Errorf("got notification %+v", payload)
+		}
+	default:
+		t.Error("pending message was not delivered again")
+	}
+
+	deadList, _ := eb.repo.GetDeadLetters()
+	if len(deadList) != 1 || deadList[0].BotId != "gone" || deadList[0].Sensor.MessageId != sensor.MessageId {
+		t.Errorf("got dead letters %+v", deadList)
+	}
+
+	if resilienceList, _ := eb.repo.GetResilienceEntries(); len(resilienceList) != 0 {
+		t.Errorf("recovered message left resilience entries %+v", resilienceList)
+	}
+	if requestList, _ := eb.repo.GetRequestEntries(); len(requestList) != 0 {
+		t.Errorf("recovered message left requests %+v", requestList)
+	}
+}
+
+func TestSpawnSensor(t *testing.T) {
+	newTestBroker(t)
+
+	if w := serveTestRequest("POST", "/sensor", `{"id":"s1","type":"nosuchtopic","msg":"20"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("sensor of an unregistered topic got %d", w.Code)
+	}
+
+	w := serveTestRequest("POST", "/sensor", `{"id":"s1","type":"temperature","msg":"20","current_sector":"A"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("sensor got %d : %s", w.Code, w.Body)
+	}
+	var ack Sensor
+	json.NewDecoder(w.Body).Decode(&ack)
+	if ack.MessageId == "" || ack.Offset == 0 {
+		t.Errorf("got ack %+v", ack)
+	}
+
+	request, _ := eb.repo.GetSensorRequest("s1", ack.MessageId)
+	if request.Message != "20" {
+		t.Errorf("request stored is %+v", request)
+	}
+	if queued := <-eb.sensorsRequest; queued.MessageId != ack.MessageId {
+		t.Errorf("queued request is %+v", queued)
+	}
+}
+
+func TestSpawnSensorRetransmission(t *testing.T) {
+	newTestBroker(t)
+
+	// retransmission of a message still pending is only acked
+	var ack Sensor
+	json.NewDecoder(serveTestRequest("POST", "/sensor", `{"id":"s1","type":"temperature","msg":"20"}`).Body).Decode(&ack)
+	<-eb.sensorsRequest
+	w := serveTestRequest("POST", "/sensor", `{"id":"s1","type":"temperature","msg":"20","pbrtx":true,"msg_id":"`+ack.MessageId+`"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("retransmission got %d : %s", w.Code, w.Body)
+	}
+	if len(eb.sensorsRequest) != 0 {
+		t.Errorf("retransmission of a pending message was queued again")
+	}
+
+	// retransmission of a message broker has no trace of is served with its msg_id, original may have been lost
+	serveTestRequest("POST", "/sensor", `{"id":"s1","type":"temperature","msg":"21","pbrtx":true,"msg_id":"lost"}`)
+	if len(eb.sensorsRequest) != 1 {
+		t.Fatalf("retransmission of an unknown message was not queued")
+	}
+	if queued := <-eb.sensorsRequest; queued.MessageId != "lost" {
+		t.Errorf("retransmission was queued as %+v", queued)
+	}
+}
+
+func TestSpawnBot(t *testing.T) {
+	newTestBroker(t)
+
+	if w := serveTestRequest("POST", "/bot", `{"id":"b1","topic":"temperature","callback_url":"localhost:1/cb"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("bot with a relative callback url got %d", w.Code)
+	}
+	if w := serveTestRequest("POST", "/bot", `{"id":"b1","topic":"temperature","mode":"carrier pigeon"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("bot with an unknown mode got %d", w.Code)
+	}
+
+	w := serveTestRequest("POST", "/bot", `{"id":"b1","topic":"temperature","callback_url":"http://localhost:1/cb","callback_headers":{"Authorization":"secret"}}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("bot got %d : %s", w.Code, w.Body)
+	}
+	if strings.Contains(w.Body.String(), "secret") {
+		t.Errorf("reply carries the callback header : %s", w.Body)
+	}
+	if myBot := findBotbyId("b1"); myBot.Topic != "temperature" || myBot.CallbackHeaders["Authorization"] != "secret" {
+		t.Errorf("bot registered is %+v", myBot)
+	}
+	if botsList, _ := eb.repo.GetBots(); len(botsList) != 1 {
+		t.Errorf("bots stored are %+v", botsList)
+	}
+
+	serveTestRequest("POST", "/unsubscribeBot", `{"id":"b1","topic":"temperature"}`)
+	if myBot := findBotbyId("b1"); myBot.Id != "" {
+		t.Errorf("bot %+v is still registered", myBot)
+	}
+	if botsList, _ := eb.repo.GetBots(); len(botsList) != 0 {
+		t.Errorf("bots stored are %+v", botsList)
+	}
+}
+
+func TestRequeueDeadLetter(t *testing.T) {
+	newTestBroker(t)
+	callback := newTestCallback(t, 0)
+	addBot(Bot{Id: "b1", Topic: "temperature", CallbackURL: callback.URL})
+
+	sensor := Sensor{Id: "s1", Type: "temperature", Message: "20"}
+	eb.AssignMessageId(&sensor)
+	eb.repo.AddDeadLetter(DeadLetter{Id: "d1", BotId: "b1", Sensor: sensor, Attempts: 10})
+
+	if w := serveTestRequest("POST", "/deadLetters/nosuchletter/requeue", ""); w.Code != http.StatusNotFound {
+		t.Errorf("requeue of a missing dead letter got %d", w.Code)
+	}
+	if w := serveTestRequest("POST", "/deadLetters/d1/requeue", ""); w.Code != http.StatusOK {
+		t.Fatalf("requeue got %d : %s", w.Code, w.Body)
+	}
+
+	// bot gets the message with the msg_id it had, as a redelivery
+	if payload := callback.next(t); payload["msg_id"] != sensor.MessageId || payload["redelivery"] != true {
+		t.Errorf("got notification %+v", payload)
+	}
+	waitRequestsRemoved(t)
+	if deadList, _ := eb.repo.GetDeadLetters(); len(deadList) != 0 {
+		t.Errorf("requeued letter is still there : %+v", deadList)
+	}
+	if resilienceList, _ := eb.repo.GetResilienceEntries(); len(resilienceList) != 0 {
+		t.Errorf("acked message left resilience entries %+v", resilienceList)
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// delivers again to bot the messages in the logs of the topics matching filter which its subscriptions get,
+// from fromOffset on and at most limit of them if limit is not 0, returns how many they are. Every message
+// is a copy for bot only, keeping its msg_id and flagged as redelivery, stored before returning and then
+// delivered and acked one at a time in offset order, like any other message
+func (eb *Broker) Replay(bot Bot, filter string, fromOffset int64, limit int) (int, error) {
+	now := time.Now()
+	messages := []Sensor{}
+
+	for _, topic := range eb.Topics() {
+		if !topicMatches(filter, topic.Name) {
+			continue
+		}
+
+		// messages not routed to bot do not count toward limit, which is applied once they are merged
+		entries, err := eb.logOf(topic, now, fromOffset, math.MaxInt64, 0)
+		if err != nil {
+			return 0, err
+		}
+		for _, sensor := range entries {
+			if eb.routedTo(bot, sensor) {
+				messages = append(messages, sensor)
+			}
+		}
+	}
+
+	sort.Slice(messages, func(i, j int) bool { return messages[i].Offset < messages[j].Offset })
+	if limit > 0 && len(messages) > limit {
+		messages = messages[:limit]
+	}
+
+	// requests are stored at once, so a crash in the middle of the replay does not lose the messages left
+	var delivered <-chan struct{}
+	for _, sensor := range messages {
+		sensor = sensor.copy()
+		sensor.Retained = false
+
+		var err error
+		if delivered, err = eb.publishTo([]Bot{bot}, sensor, true, delivered); err != nil {
+			return 0, err
+		}
+	}
+	return len(messages), nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func serveReplay(botId string, body string) (int, BotReplay) {
+	router := mux.NewRouter()
+	router.HandleFunc("/bot/{id}/replay", replayMessages).Methods("POST")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/bot/"+botId+"/replay", strings.NewReader(body)))
+	var replay BotReplay
+	json.Unmarshal(w.Body.Bytes(), &replay)
+	return w.Code, replay
+}
+
+func TestReplay(t *testing.T) {
+	newTestBroker(t)
+	callback := newTestCallback(t, 0)
+	addBot(Bot{Id: "r1", CallbackURL: callback.URL, Subscriptions: []Subscription{{Topic: "temperature", Sectors: []string{"A"}}}})
+
+	// messages are logged as they are accepted, no worker publishes them
+	acks := []Sensor{}
+	for i, sector := range []string{"A", "B", "A", "A"} {
+		ack, err := acceptSensor(Sensor{Id: "s1", Type: "temperature", CurrentSector: sector, Message: fmt.Sprint(i)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		acks = append(acks, ack)
+	}
+	// as if bot got them long ago
+	for range acks {
+		queued := <-eb.sensorsRequest
+		eb.repo.RemovePubRequest(queued.Id, queued.MessageId)
+	}
+
+	code, replay := serveReplay("r1", fmt.Sprintf(`{"topic":"temperature","from_offset":%d}`, acks[1].Offset))
+	if code != http.StatusOK || replay.Messages != 2 {
+		t.Fatalf("replay got %d : %+v", code, replay)
+	}
+
+	// only messages of sectors bot is subscribed to, in offset order, with their msg_id
+	for _, k := range []int{2, 3} {
+		payload := callback.next(t)
+		if payload["msg_id"] != acks[k].MessageId || payload["msg"] != fmt.Sprint(k) || payload["redelivery"] != true {
+			t.Errorf("replayed message %d is %+v", k, payload)
+		}
+	}
+	waitRequestsRemoved(t)
+	if resilienceList, _ := eb.repo.GetResilienceEntries(); len(resilienceList) != 0 {
+		t.Errorf("replay left resilience entries %+v", resilienceList)
+	}
+
+	from := time.Now().Add(-time.Hour).Format(time.RFC3339)
+	if code, replay := serveReplay("r1", `{"topic":"#","from":"`+from+`","limit":1}`); code != http.StatusOK || replay.Messages != 1 {
+		t.Errorf("replay from time got %d : %+v", code, replay)
+	}
+	callback.next(t)
+	waitRequestsRemoved(t)
+
+	for _, body := range []string{
+		`{"topic":"temperature"}`,
+		`{"topic":"temperature/#","from_offset":3}`,
+		`{"topic":"temperature","from_offset":3,"from":"2020-01-01T00:00:00Z"}`,
+		`{"topic":"nosuchtopic","from_offset":3}`,
+	} {
+		if code, _ := serveReplay("r1", body); code != http.StatusBadRequest {
+			t.Errorf("replay %s got %d", body, code)
+		}
+	}
+	if code, _ := serveReplay("nosuchbot", `{"topic":"temperature","from_offset":3}`); code != http.StatusNotFound {
+		t.Errorf("replay to a missing bot got %d", code)
+	}
+}
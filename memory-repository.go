@@ -0,0 +1,365 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// composite key (id, msg_id) used by sensorsRequest and resilience tables, (type, current_sector) by retained table
+type tableKey struct {
+	Id        string
+	MessageId string
+}
+
+// MemoryRepository is a Repository which keeps tables in process memory, with the same keys used on DynamoDB:
+// bots by id, sensorsRequest by (id, msg_id) and resilience by (botId + sensorId, msg_id).
+// Nothing survives a restart, so it is meant for tests and ephemeral brokers
+type MemoryRepository struct {
+	lock   sync.RWMutex
+	tables bool
+
+	bots       map[string]Bot
+	requests   map[tableKey]Sensor
+	resilience map[tableKey]resilienceEntry
+	dead       map[string]DeadLetter
+	topics     map[string]Topic
+	history    map[string][]Sensor // messages of every topic, sorted by offset
+	retained   map[tableKey]Sensor // by (type, current_sector)
+	settings   map[string]Setting
+}
+
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{
+		bots:       map[string]Bot{},
+		requests:   map[tableKey]Sensor{},
+		resilience: map[tableKey]resilienceEntry{},
+		dead:       map[string]DeadLetter{},
+		topics:     map[string]Topic{},
+		history:    map[string][]Sensor{},
+		retained:   map[tableKey]Sensor{},
+		settings:   map[string]Setting{},
+	}
+}
+
+func (repo *MemoryRepository) ExistingTables() (int, error) {
+	repo.lock.RLock()
+	defer repo.lock.RUnlock()
+
+	if repo.tables {
+		return len(repositoryTables), nil
+	}
+	return 0, nil
+}
+
+func (repo *MemoryRepository) CreateTables() error {
+	repo.lock.Lock()
+	repo.tables = true
+	repo.lock.Unlock()
+
+	fmt.Println("Created the tables in memory")
+	return nil
+}
+
+func (repo *MemoryRepository) AddBot(bot Bot) error {
+	repo.lock.Lock()
+	defer repo.lock.Unlock()
+
+	repo.bots[bot.Id] = bot
+	return nil
+}
+
+func (repo *MemoryRepository) GetBots() ([]Bot, error) {
+	repo.lock.RLock()
+	defer repo.lock.RUnlock()
+
+	var botslist = []Bot{}
+	for _, bot := range repo.bots {
+		botslist = append(botslist, bot)
+	}
+	return botslist, nil
+}
+
+func (repo *MemoryRepository) RemoveBot(id string) error {
+	repo.lock.Lock()
+	defer repo.lock.Unlock()
+
+	delete(repo.bots, id)
+	return nil
+}
+
+func (repo *MemoryRepository) AddSensorRequest(sensor Sensor) error {
+	repo.lock.Lock()
+	defer repo.lock.Unlock()
+
+	repo.requests[tableKey{sensor.Id, sensor.MessageId}] = sensor
+	return nil
+}
+
+func (repo *MemoryRepository) GetRequestEntries() ([]Sensor, error) {
+	repo.lock.RLock()
+	defer repo.lock.RUnlock()
+
+	var requestList = []Sensor{}
+	for _, sensor := range repo.requests {
+		requestList = append(requestList, sensor)
+	}
+	return requestList, nil
+}
+
+func (repo *MemoryRepository) GetSensorRequest(sensorId string, msgId string) (Sensor, error) {
+	repo.lock.RLock()
+	defer repo.lock.RUnlock()
+
+	return repo.requests[tableKey{sensorId, msgId}], nil
+}
+
+func (repo *MemoryRepository) RemovePubRequest(sensorId string, msgId string) error {
+	repo.lock.Lock()
+	defer repo.lock.Unlock()
+
+	delete(repo.requests, tableKey{sensorId, msgId})
+	return nil
+}
+
+func (repo *MemoryRepository) WriteBotIdsAndMessage(botsArray []Bot, sensor Sensor) error {
+	repo.lock.Lock()
+	defer repo.lock.Unlock()
+
+	for _, item := range resilienceEntriesOf(botsArray, sensor) {
+		repo.resilience[tableKey{item.Id, item.MessageId}] = item
+	}
+	return nil
+}
+
+// returns the resilience entries left to botsArray by sensor message, keyed by (botId + sensorId, msg_id)
+func resilienceEntriesOf(botsArray []Bot, sensor Sensor) []resilienceEntry {
+	var entries []resilienceEntry
+	for _, bot := range botsArray {
+
+		var item resilienceEntry
+		item.Id = bot.Id + sensor.Id
+		item.Message = sensor.Message
+		item.MessageId = sensor.MessageId
+		item.BotId = bot.Id
+		item.Sensor = sensor
+
+		entries = append(entries, item)
+	}
+	return entries
+}
+
+// stores a resilience entry as it is, used by repositories which reload entries written before
+func (repo *MemoryRepository) putResilienceEntry(item resilienceEntry) {
+	repo.lock.Lock()
+	defer repo.lock.Unlock()
+
+	repo.resilience[tableKey{item.Id, item.MessageId}] = item
+}
+
+func (repo *MemoryRepository) GetResilienceEntries() ([]resilienceEntry, error) {
+	repo.lock.RLock()
+	defer repo.lock.RUnlock()
+
+	var resilienceList = []resilienceEntry{}
+	for _, entry := range repo.resilience {
+		resilienceList = append(resilienceList, entry)
+	}
+	return resilienceList, nil
+}
+
+func (repo *MemoryRepository) GetBotResilienceEntries(botId string) ([]resilienceEntry, error) {
+	repo.lock.RLock()
+	defer repo.lock.RUnlock()
+
+	var resilienceList = []resilienceEntry{}
+	for _, entry := range repo.resilience {
+		if entry.BotId == botId {
+			resilienceList = append(resilienceList, entry)
+		}
+	}
+	return resilienceList, nil
+}
+
+func (repo *MemoryRepository) RemoveResilienceEntry(botId string, msgId string, sensor string) error {
+	repo.lock.Lock()
+	defer repo.lock.Unlock()
+
+	delete(repo.resilience, tableKey{botId + sensor, msgId})
+	return nil
+}
+
+func (repo *MemoryRepository) AddDeadLetter(letter DeadLetter) error {
+	repo.lock.Lock()
+	defer repo.lock.Unlock()
+
+	repo.dead[letter.Id] = letter
+	return nil
+}
+
+func (repo *MemoryRepository) GetDeadLetters() ([]DeadLetter, error) {
+	repo.lock.RLock()
+	defer repo.lock.RUnlock()
+
+	var deadList = []DeadLetter{}
+	for _, letter := range repo.dead {
+		deadList = append(deadList, letter)
+	}
+	return deadList, nil
+}
+
+func (repo *MemoryRepository) GetDeadLetter(id string) (DeadLetter, error) {
+	repo.lock.RLock()
+	defer repo.lock.RUnlock()
+
+	return repo.dead[id], nil
+}
+
+func (repo *MemoryRepository) RemoveDeadLetter(id string) error {
+	repo.lock.Lock()
+	defer repo.lock.Unlock()
+
+	delete(repo.dead, id)
+	return nil
+}
+
+func (repo *MemoryRepository) AddTopic(topic Topic) error {
+	repo.lock.Lock()
+	defer repo.lock.Unlock()
+
+	repo.topics[topic.Name] = topic
+	return nil
+}
+
+func (repo *MemoryRepository) GetTopics() ([]Topic, error) {
+	repo.lock.RLock()
+	defer repo.lock.RUnlock()
+
+	var topicList = []Topic{}
+	for _, topic := range repo.topics {
+		topicList = append(topicList, topic)
+	}
+	return topicList, nil
+}
+
+func (repo *MemoryRepository) RemoveTopic(name string) error {
+	repo.lock.Lock()
+	defer repo.lock.Unlock()
+
+	delete(repo.topics, name)
+	return nil
+}
+
+func (repo *MemoryRepository) AddHistoryEntry(sensor Sensor) error {
+	repo.lock.Lock()
+	defer repo.lock.Unlock()
+
+	// broker appends messages in offset order, a file loaded at startup may not be in order
+	entries := repo.history[sensor.Type]
+	k := sort.Search(len(entries), func(i int) bool { return entries[i].Offset >= sensor.Offset })
+	if k < len(entries) && entries[k].Offset == sensor.Offset {
+		entries[k] = sensor
+		return nil
+	}
+	entries = append(entries, Sensor{})
+	copy(entries[k+1:], entries[k:])
+	entries[k] = sensor
+	repo.history[sensor.Type] = entries
+	return nil
+}
+
+func (repo *MemoryRepository) GetHistory(topic string, fromOffset int64, toOffset int64, limit int) ([]Sensor, error) {
+	repo.lock.RLock()
+	defer repo.lock.RUnlock()
+
+	entries := repo.history[topic]
+	k := sort.Search(len(entries), func(i int) bool { return entries[i].Offset >= fromOffset })
+
+	var historyList = []Sensor{}
+	for _, sensor := range entries[k:] {
+		if sensor.Offset > toOffset || limit > 0 && len(historyList) == limit {
+			break
+		}
+		historyList = append(historyList, sensor)
+	}
+	return historyList, nil
+}
+
+func (repo *MemoryRepository) GetLastHistory(topic string, n int) ([]Sensor, error) {
+	repo.lock.RLock()
+	defer repo.lock.RUnlock()
+
+	entries := repo.history[topic]
+	if len(entries) > n {
+		entries = entries[len(entries)-n:]
+	}
+	return append([]Sensor{}, entries...), nil
+}
+
+func (repo *MemoryRepository) RemoveHistory(topic string, untilOffset int64) error {
+	repo.lock.Lock()
+	defer repo.lock.Unlock()
+
+	entries := repo.history[topic]
+	k := sort.Search(len(entries), func(i int) bool { return entries[i].Offset > untilOffset })
+	if k == len(entries) {
+		delete(repo.history, topic)
+	} else {
+		repo.history[topic] = append([]Sensor{}, entries[k:]...)
+	}
+	return nil
+}
+
+func (repo *MemoryRepository) AddRetained(sensor Sensor) error {
+	repo.lock.Lock()
+	defer repo.lock.Unlock()
+
+	repo.retained[tableKey{sensor.Type, sensor.CurrentSector}] = sensor
+	return nil
+}
+
+func (repo *MemoryRepository) GetRetained() ([]Sensor, error) {
+	repo.lock.RLock()
+	defer repo.lock.RUnlock()
+
+	var retainedList = []Sensor{}
+	for _, sensor := range repo.retained {
+		retainedList = append(retainedList, sensor)
+	}
+	return retainedList, nil
+}
+
+func (repo *MemoryRepository) RemoveRetained(topic string, sector string) error {
+	repo.lock.Lock()
+	defer repo.lock.Unlock()
+
+	delete(repo.retained, tableKey{topic, sector})
+	return nil
+}
+
+func (repo *MemoryRepository) AddSetting(setting Setting) error {
+	repo.lock.Lock()
+	defer repo.lock.Unlock()
+
+	repo.settings[setting.Name] = setting
+	return nil
+}
+
+func (repo *MemoryRepository) GetSettings() ([]Setting, error) {
+	repo.lock.RLock()
+	defer repo.lock.RUnlock()
+
+	var settingList = []Setting{}
+	for _, setting := range repo.settings {
+		settingList = append(settingList, setting)
+	}
+	return settingList, nil
+}
+
+func (repo *MemoryRepository) RemoveSetting(name string) error {
+	repo.lock.Lock()
+	defer repo.lock.Unlock()
+
+	delete(repo.settings, name)
+	return nil
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestMemoryRepositoryTables(t *testing.T) {
+	repo := NewMemoryRepository()
+
+	if tablesNumber, _ := repo.ExistingTables(); tablesNumber != 0 {
+		t.Fatalf("new repository has %d tables", tablesNumber)
+	}
+	if err := repo.CreateTables(); err != nil {
+		t.Fatal(err)
+	}
+	if tablesNumber, _ := repo.ExistingTables(); tablesNumber != len(repositoryTables) {
+		t.Fatalf("repository has %d tables after CreateTables, want %d", tablesNumber, len(repositoryTables))
+	}
+}
+
+func TestMemoryRepositoryBots(t *testing.T) {
+	repo := NewMemoryRepository()
+
+	repo.AddBot(Bot{Id: "b1", Topic: "temperature"})
+	repo.AddBot(Bot{Id: "b2", Topic: "humidity"})
+	repo.AddBot(Bot{Id: "b1", Topic: "motion"})
+
+	botsList, _ := repo.GetBots()
+	if len(botsList) != 2 {
+		t.Fatalf("got %d bots, want 2", len(botsList))
+	}
+	for _, bot := range botsList {
+		if bot.Id == "b1" && bot.Topic != "motion" {
+			t.Errorf("bot b1 was not replaced : %+v", bot)
+		}
+	}
+
+	repo.RemoveBot("b1")
+	if botsList, _ := repo.GetBots(); len(botsList) != 1 || botsList[0].Id != "b2" {
+		t.Errorf("got %+v after removing b1", botsList)
+	}
+}
+
+func TestMemoryRepositorySensorRequests(t *testing.T) {
+	repo := NewMemoryRepository()
+
+	sensor := Sensor{Id: "s1", Message: "20", Type: "temperature", MessageId: "m1"}
+	repo.AddSensorRequest(sensor)
+	repo.AddSensorRequest(Sensor{Id: "s1", Message: "20", Type: "temperature", MessageId: "m2"})
+
+	if request, _ := repo.GetSensorRequest("s1", "m1"); request.MessageId != "m1" {
+		t.Errorf("got %+v for (s1, m1)", request)
+	}
+	if request, _ := repo.GetSensorRequest("s1", "m3"); request.Id != "" {
+		t.Errorf("got %+v for missing (s1, m3)", request)
+	}
+
+	repo.RemovePubRequest("s1", "m1")
+	if requestList, _ := repo.GetRequestEntries(); len(requestList) != 1 || requestList[0].MessageId != "m2" {
+		t.Errorf("got %+v after removing (s1, m1)", requestList)
+	}
+}
+
+func TestMemoryRepositoryResilience(t *testing.T) {
+	repo := NewMemoryRepository()
+
+	sensor := Sensor{Id: "s1", Message: "20", MessageId: "m1"}
+	repo.WriteBotIdsAndMessage([]Bot{{Id: "b1"}, {Id: "b2"}}, sensor)
+	repo.WriteBotIdsAndMessage([]Bot{{Id: "b1"}}, Sensor{Id: "s2", Message: "21", MessageId: "m2"})
+
+	if resilience, _ := repo.GetResilienceEntries(); len(resilience) != 3 {
+		t.Fatalf("got %d resilience entries, want 3", len(resilience))
+	}
+
+	entries, _ := repo.GetBotResilienceEntries("b1")
+	if len(entries) != 2 {
+		t.Fatalf("got %d resilience entries of b1, want 2", len(entries))
+	}
+	for _, entry := range entries {
+		if entry.BotId != "b1" || entry.Id != "b1"+entry.Sensor.Id || entry.Sensor.MessageId != entry.MessageId {
+			t.Errorf("wrong resilience entry of b1 : %+v", entry)
+		}
+	}
+
+	repo.RemoveResilienceEntry("b1", "m1", "s1")
+	if entries, _ := repo.GetBotResilienceEntries("b1"); len(entries) != 1 || entries[0].MessageId != "m2" {
+		t.Errorf("got %+v after removing (b1, m1)", entries)
+	}
+	if entries, _ := repo.GetBotResilienceEntries("b2"); len(entries) != 1 {
+		t.Errorf("entry of b2 was removed with the one of b1 : %+v", entries)
+	}
+}
+
+func TestMemoryRepositoryDeadLetters(t *testing.T) {
+	repo := NewMemoryRepository()
+
+	repo.AddDeadLetter(DeadLetter{Id: "d1", BotId: "b1", Attempts: 3})
+	repo.AddDeadLetter(DeadLetter{Id: "d2", BotId: "b2"})
+
+	if letter, _ := repo.GetDeadLetter("d1"); letter.BotId != "b1" || letter.Attempts != 3 {
+		t.Errorf("got %+v for d1", letter)
+	}
+	if letter, _ := repo.GetDeadLetter("d3"); letter.Id != "" {
+		t.Errorf("got %+v for missing d3", letter)
+	}
+
+	repo.RemoveDeadLetter("d1")
+	if deadList, _ := repo.GetDeadLetters(); len(deadList) != 1 || deadList[0].Id != "d2" {
+		t.Errorf("got %+v after removing d1", deadList)
+	}
+}
+
+func TestMemoryRepositoryHistory(t *testing.T) {
+	repo := NewMemoryRepository()
+
+	// appends out of offset order, as a file loaded at startup may be
+	for _, offset := range []int64{30, 10, 20, 40} {
+		repo.AddHistoryEntry(Sensor{Id: "s1", Type: "temperature", Offset: offset})
+	}
+	repo.AddHistoryEntry(Sensor{Id: "s1", Type: "humidity", Offset: 15})
+
+	historyList, _ := repo.GetHistory("temperature", 0, math.MaxInt64, 0)
+	if len(historyList) != 4 {
+		t.Fatalf("got %d messages of temperature, want 4", len(historyList))
+	}
+	for k, offset := range []int64{10, 20, 30, 40} {
+		if historyList[k].Offset != offset {
+			t.Fatalf("history is not sorted by offset : %+v", historyList)
+		}
+	}
+
+	if historyList, _ := repo.GetHistory("temperature", 20, 30, 0); len(historyList) != 2 {
+		t.Errorf("got %+v between offsets 20 and 30", historyList)
+	}
+	if historyList, _ := repo.GetHistory("temperature", 15, math.MaxInt64, 2); len(historyList) != 2 || historyList[0].Offset != 20 || historyList[1].Offset != 30 {
+		t.Errorf("got %+v for the first 2 messages from offset 15", historyList)
+	}
+	if historyList, _ := repo.GetLastHistory("temperature", 2); len(historyList) != 2 || historyList[0].Offset != 30 || historyList[1].Offset != 40 {
+		t.Errorf("got %+v for the last 2 messages", historyList)
+	}
+	if historyList, _ := repo.GetLastHistory("temperature", 10); len(historyList) != 4 {
+		t.Errorf("got %+v for the last 10 of 4 messages", historyList)
+	}
+
+	repo.RemoveHistory("temperature", 20)
+	if historyList, _ := repo.GetHistory("temperature", 0, math.MaxInt64, 0); len(historyList) != 2 || historyList[0].Offset != 30 {
+		t.Errorf("got %+v after removing until offset 20", historyList)
+	}
+	if historyList, _ := repo.GetHistory("humidity", 0, math.MaxInt64, 0); len(historyList) != 1 {
+		t.Errorf("log of humidity changed with the one of temperature : %+v", historyList)
+	}
+}
+
+func TestMemoryRepositoryRetained(t *testing.T) {
+	repo := NewMemoryRepository()
+
+	repo.AddRetained(Sensor{Id: "s1", Type: "temperature", CurrentSector: "A", Message: "20"})
+	repo.AddRetained(Sensor{Id: "s2", Type: "temperature", CurrentSector: "A", Message: "21"})
+	repo.AddRetained(Sensor{Id: "s1", Type: "temperature", CurrentSector: "B", Message: "22"})
+
+	retainedList, _ := repo.GetRetained()
+	if len(retainedList) != 2 {
+		t.Fatalf("got %d retained messages, want one per topic and sector", len(retainedList))
+	}
+	for _, sensor := range retainedList {
+		if sensor.CurrentSector == "A" && sensor.Message != "21" {
+			t.Errorf("retained message of sector A was not replaced : %+v", sensor)
+		}
+	}
+
+	repo.RemoveRetained("temperature", "A")
+	if retainedList, _ := repo.GetRetained(); len(retainedList) != 1 || retainedList[0].CurrentSector != "B" {
+		t.Errorf("got %+v after removing sector A", retainedList)
+	}
+}
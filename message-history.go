@@ -0,0 +1,187 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// how often messages past the retention of their topic are removed
+const retentionInterval = time.Minute
+
+// every topic has an append-only log of its messages, each one with an offset increasing with the order
+// messages are appended. Offsets come from the same clock as seq numbers, so they are unique in the broker
+// and an offset is also the time, in microseconds since epoch, its message was appended at
+
+// RetentionPolicy tells how long messages of a topic are kept in its log after being published,
+// for max_age_ms or as the last max_messages ones. When both are set a message goes as soon as one is exceeded
+type RetentionPolicy struct {
+	MaxAgeMs    int64 `json:"max_age_ms,omitempty"`
+	MaxMessages int   `json:"max_messages,omitempty"`
+}
+
+// retention of topics which have not got their own
+var defaultRetentionPolicy = RetentionPolicy{MaxAgeMs: 24 * 60 * 60 * 1000}
+
+// HistoryEntry is a message of a topic log, with the time it was published at
+type HistoryEntry struct {
+	Sensor
+	PublishedAt time.Time `json:"published_at"`
+}
+
+func (policy *RetentionPolicy) validate() error {
+	if policy == nil {
+		return nil
+	}
+	if policy.MaxAgeMs < 0 || policy.MaxMessages < 0 {
+		return errors.New("retention max_age_ms and max_messages cannot be negative")
+	}
+	if policy.MaxAgeMs == 0 && policy.MaxMessages == 0 {
+		return errors.New("retention needs max_age_ms or max_messages")
+	}
+	return nil
+}
+
+// returns offset of the oldest message policy keeps at now, 0 if it keeps messages of any age
+func (policy RetentionPolicy) oldestOffset(now time.Time) int64 {
+	if policy.MaxAgeMs == 0 {
+		return 0
+	}
+	return seqAt(now.Add(-time.Duration(policy.MaxAgeMs) * time.Millisecond))
+}
+
+// returns retention of topic, the default one unless topic has its own
+func (topic Topic) retention() RetentionPolicy {
+	if topic.Retention != nil {
+		return *topic.Retention
+	}
+	return defaultRetentionPolicy
+}
+
+// seq numbers and offsets are the microseconds since epoch of the publish, made unique by nextSeq
+func seqAt(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Microsecond)
+}
+
+func timeOfSeq(seq int64) time.Time {
+	return time.Unix(0, seq*int64(time.Microsecond))
+}
+
+// appends sensor message to the log of its topic, setting its offset
+func (eb *Broker) appendToLog(sensor *Sensor) error {
+	// offsets of a topic are taken and appended one at a time, so its log is always appended in offset
+	// order, while messages of other topics are appended at the same time
+	lock := eb.logLockOf(sensor.Type)
+	lock.Lock()
+	defer lock.Unlock()
+
+	sensor.Offset = eb.nextSeq()
+	return eb.repo.AddHistoryEntry(*sensor)
+}
+
+// returns the lock serializing appends to the log of topic
+func (eb *Broker) logLockOf(topic string) *sync.Mutex {
+	eb.logLock.Lock()
+	defer eb.logLock.Unlock()
+
+	lock, found := eb.logLocks[topic]
+	if !found {
+		lock = &sync.Mutex{}
+		eb.logLocks[topic] = lock
+	}
+	return lock
+}
+
+// returns offset of the oldest message of topic log its retention keeps at now
+func (eb *Broker) oldestKeptOffset(topic Topic, now time.Time) (int64, error) {
+	retention := topic.retention()
+	oldest := retention.oldestOffset(now)
+
+	if retention.MaxMessages > 0 {
+		entries, err := eb.repo.GetLastHistory(topic.Name, retention.MaxMessages)
+		if err != nil {
+			return 0, err
+		}
+		if len(entries) == retention.MaxMessages && entries[0].Offset > oldest {
+			oldest = entries[0].Offset
+		}
+	}
+	return oldest, nil
+}
+
+// returns messages of topic log still within its retention with fromOffset <= offset <= toOffset,
+// oldest first and at most limit of them if limit is not 0
+func (eb *Broker) logOf(topic Topic, now time.Time, fromOffset int64, toOffset int64, limit int) ([]Sensor, error) {
+	// retention is applied here too, since messages past it are only removed every retentionInterval
+	oldest, err := eb.oldestKeptOffset(topic, now)
+	if err != nil {
+		return nil, err
+	}
+	if fromOffset < oldest {
+		fromOffset = oldest
+	}
+	return eb.repo.GetHistory(topic.Name, fromOffset, toOffset, limit)
+}
+
+// returns messages in the logs of the topics matching filter, sent from sector if not empty and
+// with fromOffset <= offset <= toOffset, oldest first and at most limit of them if limit is not 0
+func (eb *Broker) History(filter string, sector string, fromOffset int64, toOffset int64, limit int) ([]HistoryEntry, error) {
+	now := time.Now()
+	history := []HistoryEntry{}
+
+	// with a sector, messages of other sectors do not count toward limit
+	topicLimit := limit
+	if sector != "" {
+		topicLimit = 0
+	}
+
+	for _, topic := range eb.Topics() {
+		if !topicMatches(filter, topic.Name) {
+			continue
+		}
+
+		entries, err := eb.logOf(topic, now, fromOffset, toOffset, topicLimit)
+		if err != nil {
+			return nil, err
+		}
+		for _, sensor := range entries {
+			if sector == "" || sensor.CurrentSector == sector {
+				history = append(history, HistoryEntry{Sensor: sensor, PublishedAt: timeOfSeq(sensor.Offset)})
+			}
+		}
+	}
+
+	sort.Slice(history, func(i, j int) bool { return history[i].Offset < history[j].Offset })
+	if limit > 0 && len(history) > limit {
+		history = history[:limit]
+	}
+	return history, nil
+}
+
+// starts a goroutine removing, every interval, messages past the retention of their topic
+func (eb *Broker) StartRetention(interval time.Duration) {
+	go func() {
+		for range time.Tick(interval) {
+			for _, topic := range eb.Topics() {
+				if err := eb.trimLog(topic); err != nil {
+					fmt.Println("Cannot apply retention of topic " + topic.Name + " : " + err.Error())
+				}
+			}
+		}
+	}()
+}
+
+// removes messages of topic log older than its retention or exceeding its max number
+func (eb *Broker) trimLog(topic Topic) error {
+	oldest, err := eb.oldestKeptOffset(topic, time.Now())
+	if err != nil {
+		return err
+	}
+
+	if oldest <= 1 {
+		return nil
+	}
+	return eb.repo.RemoveHistory(topic.Name, oldest-1)
+}
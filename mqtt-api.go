@@ -0,0 +1,382 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/eclipse/paho.mqtt.golang/packets"
+	"github.com/lithammer/shortuuid"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// bots subscribed through MQTT get their messages as QoS 1 PUBLISH on their connection and ack them with PUBACK
+const mqttMode = "mqtt"
+
+// MQTT front-end of the broker: clients publish sensor messages on "<type>/<sector>" topics and bots subscribe
+// to the same topics, so they are routed like sensors and bots of REST API. Client id is the id of the sensor
+// publishing and of the bot subscribing
+func serveMQTT(address string) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			log.Fatal(err)
+		}
+		go serveMQTTClient(conn)
+	}
+}
+
+// connection of an MQTT client, deliveries to its bot wait for the PUBACK of their packet id
+type mqttConn struct {
+	conn      net.Conn
+	writeLock sync.Mutex
+
+	nextPacketId uint16
+	inFlight     map[uint16]string // packet id --> msg_id of deliveries awaiting PUBACK
+	inFlightLock sync.Mutex
+}
+
+// msg_id given to the last QoS 1 message of every packet id of every MQTT client: a DUP retransmission,
+// also sent on a new connection of the same session, gets the msg_id of the message it retransmits
+var mqttPublished = map[string]map[uint16]string{}
+var mqttPublishedLock sync.Mutex
+
+// returns msg_id of the last message clientId published with packetId, empty if there is none
+func publishedMsgId(clientId string, packetId uint16) string {
+	mqttPublishedLock.Lock()
+	defer mqttPublishedLock.Unlock()
+
+	return mqttPublished[clientId][packetId]
+}
+
+func setPublishedMsgId(clientId string, packetId uint16, msgId string) {
+	mqttPublishedLock.Lock()
+	defer mqttPublishedLock.Unlock()
+
+	if mqttPublished[clientId] == nil {
+		mqttPublished[clientId] = map[uint16]string{}
+	}
+	mqttPublished[clientId][packetId] = msgId
+}
+
+// forgets messages published by clientId, once its session is over
+func forgetPublished(clientId string) {
+	mqttPublishedLock.Lock()
+	defer mqttPublishedLock.Unlock()
+
+	delete(mqttPublished, clientId)
+}
+
+func (client *mqttConn) write(packet packets.ControlPacket) error {
+	client.writeLock.Lock()
+	defer client.writeLock.Unlock()
+
+	client.conn.SetWriteDeadline(time.Now().Add(streamAckTimeout))
+	return packet.Write(client.conn)
+}
+
+// sends a delivery payload to the bot as QoS 1 PUBLISH on "<topic>/<sensor sector>", with RETAIN flag
+// set on retained messages as MQTT brokers do
+func (client *mqttConn) publish(payload map[string]interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	msgId, _ := payload["msg_id"].(string)
+	topic, _ := payload["topic"].(string)
+	sector, _ := payload["sensor_cs"].(string)
+
+	client.inFlightLock.Lock()
+	client.nextPacketId++
+	if client.nextPacketId == 0 {
+		client.nextPacketId++
+	}
+	packetId := client.nextPacketId
+	client.inFlight[packetId] = msgId
+	client.inFlightLock.Unlock()
+
+	publish := packets.NewControlPacket(packets.Publish).(*packets.PublishPacket)
+	publish.Qos = 1
+	publish.Dup, _ = payload["redelivery"].(bool)
+	publish.Retain, _ = payload["retained"].(bool)
+	publish.TopicName = topic + "/" + sector
+	publish.MessageID = packetId
+	publish.Payload = body
+	return client.write(publish)
+}
+
+// forgets packet ids of msgId, once its delivery is acked or timed out
+func (client *mqttConn) forget(msgId string) {
+	client.inFlightLock.Lock()
+	defer client.inFlightLock.Unlock()
+
+	for packetId, inFlightMsgId := range client.inFlight {
+		if inFlightMsgId == msgId {
+			delete(client.inFlight, packetId)
+		}
+	}
+}
+
+// returns the msg_id acked by PUBACK of packetId
+func (client *mqttConn) acked(packetId uint16) string {
+	client.inFlightLock.Lock()
+	defer client.inFlightLock.Unlock()
+
+	msgId := client.inFlight[packetId]
+	delete(client.inFlight, packetId)
+	return msgId
+}
+
+func serveMQTTClient(conn net.Conn) {
+	defer conn.Close()
+
+	client := &mqttConn{conn: conn, inFlight: map[uint16]string{}}
+	reader := bufio.NewReader(conn)
+
+	//first packet must be CONNECT
+	conn.SetReadDeadline(time.Now().Add(streamAckTimeout))
+	first, err := packets.ReadPacket(reader)
+	if err != nil {
+		return
+	}
+	connect, ok := first.(*packets.ConnectPacket)
+	if !ok {
+		return
+	}
+
+	connack := packets.NewControlPacket(packets.Connack).(*packets.ConnackPacket)
+	connack.ReturnCode = connect.Validate()
+	if err := client.write(connack); err != nil || connack.ReturnCode != packets.Accepted {
+		return
+	}
+
+	clientId := connect.ClientIdentifier
+	if clientId == "" {
+		clientId = shortuuid.New()
+	}
+
+	//a clean session starts and ends with the connection, so do the messages it published
+	if connect.CleanSession {
+		forgetPublished(clientId)
+		defer forgetPublished(clientId)
+	}
+
+	// a client which does not talk for one and a half times its keep alive is gone
+	readTimeout := time.Duration(connect.Keepalive) * 1500 * time.Millisecond
+
+	stream := newBotStream(client.publish)
+	stream.forget = client.forget
+	defer close(stream.closed)
+
+	var myBot Bot
+	defer func() {
+		if myBot.Id == "" {
+			return
+		}
+		eb.unregisterStream(myBot.Id, stream)
+		//a clean session ends with the connection, so does the subscription
+		if connect.CleanSession {
+			removeBot(myBot)
+		}
+	}()
+
+	for {
+		if readTimeout > 0 {
+			conn.SetReadDeadline(time.Now().Add(readTimeout))
+		} else {
+			conn.SetReadDeadline(time.Time{})
+		}
+
+		packet, err := packets.ReadPacket(reader)
+		if err != nil {
+			return
+		}
+
+		switch packet := packet.(type) {
+
+		case *packets.PublishPacket:
+			if err := mqttPublish(client, clientId, packet); err != nil {
+				fmt.Println("Dropping MQTT client " + clientId + " : " + err.Error())
+				return
+			}
+
+		case *packets.PubackPacket:
+			if msgId := client.acked(packet.MessageID); msgId != "" {
+				stream.signalAck(msgId)
+			}
+
+		case *packets.SubscribePacket:
+			suback := packets.NewControlPacket(packets.Suback).(*packets.SubackPacket)
+			suback.MessageID = packet.MessageID
+			for _, filter := range packet.Topics {
+				returnCode := byte(0x80)
+				if subscribed, err := mqttSubscribe(myBot, clientId, filter, stream); err == nil {
+					myBot = subscribed
+					returnCode = 1
+				} else {
+					fmt.Println("MQTT client " + clientId + " cannot subscribe to " + filter + " : " + err.Error())
+				}
+				suback.ReturnCodes = append(suback.ReturnCodes, returnCode)
+			}
+			if err := client.write(suback); err != nil {
+				return
+			}
+
+		case *packets.UnsubscribePacket:
+			for _, filter := range packet.Topics {
+				if myBot.Id == "" {
+					break
+				}
+				subscription, err := mqttSubscription(filter)
+				if err != nil {
+					continue
+				}
+				if unsubscribed, found, err := unsubscribeTopic(myBot.Id, subscription.Topic); err != nil {
+					fmt.Println("MQTT client " + clientId + " cannot unsubscribe from " + filter + " : " + err.Error())
+				} else if found {
+					myBot = unsubscribed
+				}
+			}
+			unsuback := packets.NewControlPacket(packets.Unsuback).(*packets.UnsubackPacket)
+			unsuback.MessageID = packet.MessageID
+			if err := client.write(unsuback); err != nil {
+				return
+			}
+
+		case *packets.PingreqPacket:
+			if err := client.write(packets.NewControlPacket(packets.Pingresp)); err != nil {
+				return
+			}
+
+		case *packets.DisconnectPacket:
+			return
+
+		default:
+			fmt.Println("Dropping MQTT client " + clientId + " : unexpected " + packet.String())
+			return
+		}
+	}
+}
+
+// publishes message of sensor clientId as a request to /sensor, QoS 1 messages are acked once stored.
+// DUP flag is the retransmission flag of the sensor, which sends back the msg_id of the message with the
+// same packet id, if the broker still knows it. A body which is a json object is also the structured
+// payload of the message, e.g. {"value": 23.5, "unit": "C"}
+func mqttPublish(client *mqttConn, clientId string, publish *packets.PublishPacket) error {
+	if publish.Qos > 1 {
+		return errors.New("QoS 2 is not supported")
+	}
+
+	sensorType, sector, err := splitMQTTTopic(publish.TopicName)
+	if err != nil {
+		return err
+	}
+
+	var newSensor Sensor
+	newSensor.Id = clientId
+	newSensor.Message = string(publish.Payload)
+	newSensor.Type = sensorType
+	newSensor.CurrentSector = sector
+	newSensor.Pbrtx = publish.Dup
+	if publish.Dup && publish.Qos == 1 {
+		newSensor.MessageId = publishedMsgId(clientId, publish.MessageID)
+	}
+
+	if body := bytes.TrimSpace(publish.Payload); len(body) > 0 && body[0] == '{' {
+		newSensor.Payload = &SensorPayload{}
+		if err := json.Unmarshal(body, newSensor.Payload); err != nil {
+			return errors.New("payload of " + publish.TopicName + " : " + err.Error())
+		}
+	}
+
+	if err := validateSensor(newSensor); err != nil {
+		return err
+	}
+	ackSensor, err := acceptSensor(newSensor)
+	if err != nil {
+		return err
+	}
+
+	if publish.Qos == 1 {
+		setPublishedMsgId(clientId, publish.MessageID, ackSensor.MessageId)
+
+		puback := packets.NewControlPacket(packets.Puback).(*packets.PubackPacket)
+		puback.MessageID = publish.MessageID
+		return client.write(puback)
+	}
+	return nil
+}
+
+// subscribes bot clientId to topic filter "<type>/<sector>", or "<type>/+" and "<type>" for any sector.
+// First subscription of the connection registers the bot
+func mqttSubscribe(myBot Bot, clientId string, filter string, stream *botStream) (Bot, error) {
+	subscription, err := mqttSubscription(filter)
+	if err != nil {
+		return myBot, err
+	}
+	if err := subscription.validate(); err != nil {
+		return myBot, err
+	}
+	if myBot.Id == "" {
+		//bot registers without subscriptions, so a bot of a previous session keeps its own
+		var newBot Bot
+		newBot.Id = clientId
+
+		myBot, err = eb.registerStream(newBot, mqttMode, stream)
+		if err != nil {
+			return myBot, err
+		}
+	}
+
+	//a bot of a previous session keeps its subscriptions and gets this one too
+	return subscribeBot(myBot.Id, subscription)
+}
+
+// returns subscription of MQTT topic filter, whose last level is the sector. Types may be hierarchical
+// and use wildcards, "<type>/#" is any sector of type and of the types below it. A filter for any sector
+// is routed globally, since MQTT bots have no current sector
+func mqttSubscription(filter string) (Subscription, error) {
+	var subscription Subscription
+
+	separator := strings.LastIndex(filter, topicSeparator)
+	sector := filter[separator+1:]
+	switch {
+	case separator < 0:
+		subscription.Topic = filter
+		subscription.Routing = globalRouting
+	case sector == singleLevelWildcard:
+		subscription.Topic = filter[:separator]
+		subscription.Routing = globalRouting
+	case sector == multiLevelWildcard:
+		subscription.Topic = filter
+		subscription.Routing = globalRouting
+	default:
+		subscription.Topic = filter[:separator]
+		subscription.Sectors = []string{sector}
+	}
+
+	if strings.ContainsAny(strings.Join(subscription.Sectors, ""), "+#") {
+		return subscription, errors.New("topic filter " + filter + " : wildcards must take a whole level")
+	}
+	return subscription, validateTopicFilter(subscription.Topic)
+}
+
+// splits a "<type>/<sector>" topic name of a sensor, sector being its last level
+func splitMQTTTopic(topic string) (string, string, error) {
+	separator := strings.LastIndex(topic, topicSeparator)
+	if separator <= 0 || separator == len(topic)-1 || strings.ContainsAny(topic, "+#") {
+		return "", "", errors.New("topic " + topic + " is not <type>/<sector>")
+	}
+	return topic[:separator], topic[separator+1:], nil
+}
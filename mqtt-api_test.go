@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"github.com/eclipse/paho.mqtt.golang/packets"
+	"math"
+	"net"
+	"testing"
+	"time"
+)
+
+// MQTT client connected to the broker through a pipe, done is closed once the broker is done with it
+type testMQTTClient struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	done   chan struct{}
+}
+
+func connectTestMQTT(t *testing.T, clientId string) *testMQTTClient {
+	conn, server := net.Pipe()
+	client := &testMQTTClient{conn: conn, reader: bufio.NewReader(conn), done: make(chan struct{})}
+	go func() {
+		serveMQTTClient(server)
+		close(client.done)
+	}()
+	t.Cleanup(func() {
+		conn.Close()
+		<-client.done
+	})
+
+	connect := packets.NewControlPacket(packets.Connect).(*packets.ConnectPacket)
+	connect.ProtocolName = "MQTT"
+	connect.ProtocolVersion = 4
+	connect.ClientIdentifier = clientId
+	connect.CleanSession = true
+	client.write(t, connect)
+
+	if connack, ok := client.read(t).(*packets.ConnackPacket); !ok || connack.ReturnCode != packets.Accepted {
+		t.Fatalf("client %s was not accepted : %v", clientId, connack)
+	}
+	return client
+}
+
+func (client *testMQTTClient) write(t *testing.T, packet packets.ControlPacket) {
+	client.conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+	if err := packet.Write(client.conn); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (client *testMQTTClient) read(t *testing.T) packets.ControlPacket {
+	client.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	packet, err := packets.ReadPacket(client.reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return packet
+}
+
+// publishes body on topic with QoS 1 and waits for its PUBACK
+func (client *testMQTTClient) publish(t *testing.T, topic string, body string, packetId uint16, dup bool) {
+	publish := packets.NewControlPacket(packets.Publish).(*packets.PublishPacket)
+	publish.TopicName = topic
+	publish.Payload = []byte(body)
+	publish.Qos = 1
+	publish.MessageID = packetId
+	publish.Dup = dup
+	client.write(t, publish)
+
+	if puback, ok := client.read(t).(*packets.PubackPacket); !ok || puback.MessageID != packetId {
+		t.Fatalf("publish %d got %v", packetId, puback)
+	}
+}
+
+// waits for a message, acks it and returns its payload
+func (client *testMQTTClient) receive(t *testing.T, topic string) map[string]interface{} {
+	publish, ok := client.read(t).(*packets.PublishPacket)
+	if !ok || publish.TopicName != topic || publish.Qos != 1 {
+		t.Fatalf("got %v, want a QoS 1 message on %s", publish, topic)
+	}
+	var payload map[string]interface{}
+	if err := json.Unmarshal(publish.Payload, &payload); err != nil {
+		t.Fatal(err)
+	}
+
+	puback := packets.NewControlPacket(packets.Puback).(*packets.PubackPacket)
+	puback.MessageID = publish.MessageID
+	client.write(t, puback)
+	return payload
+}
+
+func TestMQTT(t *testing.T) {
+	newTestBroker(t)
+	eb.StartPublishers(1)
+
+	myBot := connectTestMQTT(t, "mb1")
+	subscribe := packets.NewControlPacket(packets.Subscribe).(*packets.SubscribePacket)
+	subscribe.Topics = []string{"temperature/+", "no/such/filter/+"}
+	subscribe.Qoss = []byte{1, 1}
+	subscribe.MessageID = 1
+	myBot.write(t, subscribe)
+	if suback, ok := myBot.read(t).(*packets.SubackPacket); !ok || len(suback.ReturnCodes) != 2 || suback.ReturnCodes[0] != 1 || suback.ReturnCodes[1] != 0x80 {
+		t.Fatalf("subscribe got %v", suback)
+	}
+
+	sensor := connectTestMQTT(t, "ms1")
+	sensor.publish(t, "temperature/A1", "21", 7, false)
+
+	payload := myBot.receive(t, "temperature/A1")
+	if payload["msg"] != "21" || payload["sensor"] != "ms1" || payload["redelivery"] != false {
+		t.Errorf("bot got %+v", payload)
+	}
+	waitRequestsRemoved(t)
+
+	// a retransmission of the same packet is the same message, which bot may get again but is logged once
+	sensor.publish(t, "temperature/A1", "21", 7, true)
+	if again := myBot.receive(t, "temperature/A1"); again["msg_id"] != payload["msg_id"] || again["redelivery"] != true {
+		t.Errorf("bot got retransmission %+v of %+v", again, payload)
+	}
+	waitRequestsRemoved(t)
+	if historyList, _ := eb.repo.GetHistory("temperature", 0, math.MaxInt64, 0); len(historyList) != 1 {
+		t.Errorf("log of temperature is %+v", historyList)
+	}
+	if resilienceList, _ := eb.repo.GetResilienceEntries(); len(resilienceList) != 0 {
+		t.Errorf("acked messages left resilience entries %+v", resilienceList)
+	}
+
+	// a clean session bot is gone with its connection
+	myBot.write(t, packets.NewControlPacket(packets.Disconnect))
+	<-myBot.done
+	if findBotbyId("mb1").Id != "" {
+		t.Error("clean session bot is still registered after disconnecting")
+	}
+}
@@ -3,66 +3,74 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"net"
+	"github.com/lithammer/shortuuid"
 	"net/http"
+	"net/url"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
-type key struct {
-	Topic  string
-	Sector string
-}
-
 // BotSlice is a slice of Bot
 type BotSlice []Bot
 
 // Broker stores the information about subscribers interested for // a particular topic
 type Broker struct {
-	subscribersCtx map[key]BotSlice
-	subscribers    map[string]BotSlice
+	subscribersCtx map[string]*topicTree // subscribers of every sector, for a context aware broker
+	subscribers    *topicTree
 	rm             sync.RWMutex // mutex protect broker against concurrent access from read and write
 
-	sensorsRequest []Sensor
-	lockQueue      sync.RWMutex
+	sensorsRequest chan Sensor   // bounded queue of publish requests served by publish workers
+	inFlight       chan struct{} // a slot for every delivery to a bot in progress, workers wait for a free one
+
+	repo Repository // storage backend for bots, sensor requests and resilience entries
+
+	retryPolicy        RetryPolicy
+	topicRetryPolicies map[string]RetryPolicy
+	policyLock         sync.RWMutex
+
+	lastSeq int64 // last sequence number given to a message
+
+	pullSignals map[string]chan struct{}  // wakes up long-polls of a pull bot when a message is written for it
+	pulled      map[tableKey]*pullHandout // (botId, msgId) already handed out to a pull bot
+	pullLock    sync.Mutex
+
+	deadLetterLock sync.Mutex // serializes giving up deliveries with moving messages of removed bots to dead letters
+
+	routing      string            // routing mode of subscriptions, unless their topic has its own
+	topicRouting map[string]string // per topic routing modes
+	routingLock  sync.RWMutex
+
+	streams    map[string]*botStream // open connections of websocket, gRPC and MQTT bots
+	streamLock sync.RWMutex
+
+	watchers  map[*watcher]bool // dashboards streaming published messages
+	watchLock sync.RWMutex
+
+	topics    map[string]registeredTopic // topic registry, sensors can only publish on these topics
+	topicLock sync.RWMutex
+
+	retained     map[retainedKey]Sensor // last message of every topic and sector
+	retainedLock sync.RWMutex
+
+	logLocks map[string]*sync.Mutex // serializes appends to the log of every topic
+	logLock  sync.Mutex             // protects logLocks
 }
 
 type subResponse struct {
-	BotId   string `json:"id"`
-	Message string `json:"message"`
+	BotId     string `json:"id"`
+	Message   string `json:"message"`
+	MessageId string `json:"msg_id"` // optional, bots not sending it are matched on message
 }
 
 func (eb *Broker) Unsubscribe(myBot Bot) {
-	bot := myBot
 	eb.rm.Lock()
-	if contextLock == true {
-		var internalKey = key{
-			Topic:  bot.Topic,
-			Sector: bot.CurrentSector,
-		}
-		// Finding bot index
-		for k, theBot := range eb.subscribersCtx[internalKey] {
-			if theBot.Id == bot.Id {
-				// remove bot
-				eb.subscribersCtx[internalKey] = append(eb.subscribersCtx[internalKey][:k], eb.subscribersCtx[internalKey][k+1:]...)
-				break
-			}
-		}
-
-	} else {
-		// Finding bot index
-		for k, theBot := range eb.subscribers[bot.Topic] {
-			if theBot.Id == bot.Id {
-				// remove bot
-				eb.subscribers[bot.Topic] = append(eb.subscribers[bot.Topic][:k], eb.subscribers[bot.Topic][k+1:]...)
-				break
-			}
-		}
-	}
+	eb.removeFromIndex(myBot)
 	eb.rm.Unlock()
 
-	err := removeBot(bot.Id)
+	err := eb.repo.RemoveBot(myBot.Id)
 	if err != nil {
 		panic("Got error in removing bot")
 	}
@@ -71,189 +79,592 @@ func (eb *Broker) Unsubscribe(myBot Bot) {
 func (eb *Broker) Subscribe(bot Bot) {
 
 	eb.rm.Lock()
-	if contextLock == true {
+	eb.addToIndex(bot)
+	eb.rm.Unlock()
+}
+
+// replaces subscriptions of oldBot with the ones of newBot at once, so no message is routed in between
+func (eb *Broker) Resubscribe(oldBot Bot, newBot Bot) {
+
+	eb.rm.Lock()
+	eb.removeFromIndex(oldBot)
+	eb.addToIndex(newBot)
+	eb.rm.Unlock()
+}
+
+// adds bot to subscribers of every topic it is subscribed to, rm must be held.
+// Both indexes get every subscription, whatever its routing mode, so that mode can be switched at runtime
+func (eb *Broker) addToIndex(bot Bot) {
+
+	for _, subscription := range bot.topicSubscriptions() {
+
+		// Without context
+		eb.subscribers.add(subscription.Topic, bot)
+
 		// Context-Aware --> same work as without context but this time we need to search for a couple <Topic, Sector>
-		var internalKey = key{
-			Topic:  bot.Topic,
-			Sector: bot.CurrentSector,
-		}
+		for _, sector := range subscription.sectorsOf(bot) {
 
-		if prev, found := eb.subscribersCtx[internalKey]; found {
-			eb.subscribersCtx[internalKey] = append(prev, bot)
-		} else {
-			eb.subscribersCtx[internalKey] = append([]Bot{}, bot)
+			if _, found := eb.subscribersCtx[sector]; !found {
+				eb.subscribersCtx[sector] = newTopicTree()
+			}
+			eb.subscribersCtx[sector].add(subscription.Topic, bot)
 		}
+	}
+}
 
-	} else {
-		// Without context
-		if prev, found := eb.subscribers[bot.Topic]; found {
-			eb.subscribers[bot.Topic] = append(prev, bot)
-		} else {
-			eb.subscribers[bot.Topic] = append([]Bot{}, bot)
+// removes bot from subscribers of every topic it is subscribed to, rm must be held
+func (eb *Broker) removeFromIndex(bot Bot) {
+
+	for _, subscription := range bot.topicSubscriptions() {
+
+		eb.subscribers.remove(subscription.Topic, bot.Id)
+
+		for _, sector := range subscription.sectorsOf(bot) {
+			if tree, found := eb.subscribersCtx[sector]; found {
+				tree.remove(subscription.Topic, bot.Id)
+			}
 		}
+	}
+}
 
+// removes bot with botId from slice, if found
+func withoutBot(slice BotSlice, botId string) BotSlice {
+	// Finding bot index
+	for k, theBot := range slice {
+		if theBot.Id == botId {
+			// remove bot
+			return append(slice[:k], slice[k+1:]...)
+		}
 	}
-	eb.rm.Unlock()
+	return slice
 }
 
+// routes sensor message to its bots and returns as soon as their deliveries are started, so that a worker
+// is not held by bots slow to ack or not answering at all
 func (eb *Broker) Publish(sensor Sensor) {
 
 	localSensor := sensor
-	eb.rm.RLock()
 
-	if contextLock == true {
-		var internalKey = key{
-			Topic:  localSensor.Type,
-			Sector: localSensor.CurrentSector,
-		}
+	//dashboards see every message, even the ones no bot is subscribed to
+	eb.notifyWatchers(localSensor)
 
-		// if eb map subscribersCtx contains current internal key, take the bot associated and process it
-		if notThebots, found := eb.subscribersCtx[internalKey]; found {
+	eb.rm.RLock()
+	myBots := eb.recipients(localSensor)
+	eb.rm.RUnlock()
 
-			myBots := append(BotSlice{}, notThebots...)
-			eb.rm.RUnlock()
+	if len(myBots) > 0 {
 
-			//main subroutine spawn a subroutine for every bot who needs to be notified and awaits
-			//for every subroutine to receive its own ack
+		//resilience entries are written before any delivery starts, then every bot is notified
+		//by its own subroutine while the worker goes on with the next request
 
-			writeBotIdsAndMessage(myBots, localSensor)
+		eb.writeBotIdsAndMessage(myBots, localSensor)
 
-			var wg sync.WaitGroup
-			//for every bot there is a subroutine which sends the message to the bot and awaits for its ack
-			for _, bot := range myBots {
+		eb.notifyAndRemove(myBots, localSensor, localSensor.Pbrtx)
 
-				myBot := bot
-				wg.Add(1)
+	} else {
 
-				go publishImplementation(myBot, localSensor, &wg)
+		eb.removePubRequest(localSensor.Id, localSensor.MessageId)
 
-			}
-			//wait all subroutines have received their acks
-			wg.Wait()
+	}
 
-			removePubRequest(localSensor.Id, localSensor.Message)
-		} else {
-			eb.rm.RUnlock()
-			removePubRequest(localSensor.Id, localSensor.Message)
-		}
+}
 
-	} else {
+// returns bots to be notified with sensor message, rm must be held. Bots routed globally are looked up
+// among subscribers of every sector, bots routed by sector among subscribers of sensor sector
+func (eb *Broker) recipients(sensor Sensor) BotSlice {
 
-		// if eb map subscribers contains current localSensor.Type, take the bot associated and process it
-		if notThebots, found := eb.subscribers[localSensor.Type]; found {
+	myBots := BotSlice{}
+	seen := map[string]bool{}
+	fields := filterFields(sensor)
 
-			myBots := append(BotSlice{}, notThebots...)
-			eb.rm.RUnlock()
+	for _, bot := range eb.subscribers.match(sensor.Type) {
+		if eb.routes(bot, sensor, fields, globalRouting) {
+			seen[bot.Id] = true
+			myBots = append(myBots, bot)
+		}
+	}
 
-			//main subroutine spawn a subroutine for every bot who needs to be notified and awaits
-			//for every subroutine to receive its own ack
+	if tree, found := eb.subscribersCtx[sensor.CurrentSector]; found {
+		for _, bot := range tree.match(sensor.Type) {
+			if !seen[bot.Id] && eb.routes(bot, sensor, fields, sectorRouting) {
+				myBots = append(myBots, bot)
+			}
+		}
+	}
+	return myBots
+}
 
-			var wg sync.WaitGroup
+// tells if a subscription of bot routed with mode wants sensor message, whose filter fields are fields
+func (eb *Broker) routes(bot Bot, sensor Sensor, fields map[string]interface{}, mode string) bool {
+	for _, subscription := range bot.topicSubscriptions() {
+		if !topicMatches(subscription.Topic, sensor.Type) || eb.routingOf(subscription, sensor.Type) != mode {
+			continue
+		}
+		if !filterMatches(subscription.Filter, fields) {
+			continue
+		}
+		//a global subscription not scoped to any sector gets messages of every sector
+		if mode == globalRouting && len(subscription.Sectors) == 0 && subscription.Scope == "" {
+			return true
+		}
+		for _, sector := range subscription.sectorsOf(bot) {
+			if sector == sensor.CurrentSector {
+				return true
+			}
+		}
+	}
+	return false
+}
 
-			writeBotIdsAndMessage(myBots, localSensor)
+// tells if sensor message is routed to bot by its subscriptions, in either routing mode
+func (eb *Broker) routedTo(bot Bot, sensor Sensor) bool {
+	fields := filterFields(sensor)
+	return eb.routes(bot, sensor, fields, globalRouting) || eb.routes(bot, sensor, fields, sectorRouting)
+}
 
-			//for every bot there is a subroutine which sends the message to the bot and awaits for its ack
-			for _, bot := range myBots {
+//spawns a subroutine for every bot which needs to be notified and returns, a completion subroutine awaits
+//for every one of them to end, then sensor request can be removed since every bot either acked or got the
+//message in dead letters. Returned channel is closed once request is removed.
+//redelivery tells bots the message may have already been sent to them.
+//Every subroutine takes a slot of inFlight, waiting for one while they are all taken, so bots slow to ack
+//hold up publish workers and then sensors instead of piling up subroutines
+func (eb *Broker) notifyAndRemove(bots []Bot, sensor Sensor, redelivery bool) <-chan struct{} {
 
-				myBot := bot
-				wg.Add(1)
+	var wg sync.WaitGroup
+	done := make(chan struct{})
 
-				go publishImplementation(myBot, localSensor, &wg)
+	//for every bot there is a subroutine which sends the message to the bot and awaits for its ack
+	for _, bot := range bots {
 
-			}
+		//pull bots fetch message from their resilience entries, they are only told it is there
+		if bot.Mode == pullMode {
+			eb.wakePuller(bot.Id)
+			continue
+		}
 
-			//wait all subroutines have received their acks
-			wg.Wait()
+		myBot := bot
+		wg.Add(1)
+		eb.inFlight <- struct{}{}
 
-			removePubRequest(localSensor.Id, localSensor.Message)
+		go func() {
+			defer wg.Done()
+			defer func() { <-eb.inFlight }()
+			eb.publishImplementation(myBot, sensor, redelivery)
+		}()
+	}
 
-		} else {
+	go func() {
+		//wait all subroutines have received their acks
+		wg.Wait()
 
-			eb.rm.RUnlock()
-			removePubRequest(localSensor.Id, localSensor.Message)
+		eb.removePubRequest(sensor.Id, sensor.MessageId)
+		close(done)
+	}()
+	return done
+}
 
-		}
+//stores sensor message as a new publish request for bots, with their resilience entries, then delivers it
+//as notifyAndRemove does once after is closed, at once if after is nil, so that messages can be delivered
+//in order. Returned channel is closed once request is removed
+func (eb *Broker) publishTo(bots []Bot, sensor Sensor, redelivery bool, after <-chan struct{}) (<-chan struct{}, error) {
 
+	if err := eb.repo.AddSensorRequest(sensor); err != nil {
+		return nil, err
+	}
+	if err := eb.repo.WriteBotIdsAndMessage(bots, sensor); err != nil {
+		return nil, err
 	}
 
+	if after == nil {
+		return eb.notifyAndRemove(bots, sensor, redelivery), nil
+	}
+	done := make(chan struct{})
+	go func() {
+		<-after
+		<-eb.notifyAndRemove(bots, sensor, redelivery)
+		close(done)
+	}()
+	return done, nil
 }
 
 //retransmits a single message to a single bot until receives an ack from it (at least one semantic)
-func publishImplementation(bot Bot, sensor Sensor, wg *sync.WaitGroup) {
+//or the retry policy of the message topic is exhausted, in which case message goes to dead letters
+func (eb *Broker) publishImplementation(bot Bot, sensor Sensor, redelivery bool) {
 
-	//subroutine awaits for the ack from the bot
 	myNewBot := bot
 	mySensor := sensor
-	myMessage := mySensor.Message
-
-	//blocking call : go function awaits for response to its http request
-	response := newRequest(myNewBot, myMessage, mySensor)
+	policy := eb.retryPolicyFor(mySensor.Type)
 
-	var dataReceived subResponse
-	err := json.NewDecoder(response.Body).Decode(&dataReceived)
+	attempts, err := policy.Do(func() error {
+		err := eb.deliverTo(myNewBot, mySensor, redelivery)
+		// every following attempt may reach a bot which got the message but whose ack was lost
+		redelivery = true
+		return err
+	})
 
 	if err == nil {
 
 		// scenario in which bot responded with ack
-		removeResilienceEntry(dataReceived.BotId, dataReceived.Message, mySensor.Id)
+		eb.removeResilienceEntry(myNewBot.Id, mySensor.MessageId, mySensor.Id)
+		return
+	}
 
-	} else if err, ok := err.(net.Error); ok && err.Timeout() {
+	//a bot removed meanwhile gets its messages in dead letters from removeBot, which moves them while holding
+	//deadLetterLock too, so either one or the other moves this one
+	eb.deadLetterLock.Lock()
+	defer eb.deadLetterLock.Unlock()
+	if findBotbyId(myNewBot.Id).Id == "" {
+		return
+	}
 
-		for {
+	fmt.Printf("Giving up delivery of message %q from sensor %s to bot %s after %d attempts : %v\n",
+		mySensor.Message, mySensor.Id, myNewBot.Id, attempts, err)
+	eb.deadLetter(myNewBot, mySensor, attempts, err)
+}
 
-			newResponse := newRequest(myNewBot, myMessage, mySensor)
-			newErr := json.NewDecoder(newResponse.Body).Decode(&dataReceived)
+// sends message to bot once on the channel it registered with
+func (eb *Broker) deliverTo(bot Bot, sensor Sensor, redelivery bool) error {
+	if bot.Mode == websocketMode || bot.Mode == grpcMode || bot.Mode == mqttMode {
+		return eb.deliverStream(bot, sensor, redelivery)
+	}
+	return deliver(bot, sensor, redelivery)
+}
 
-			// got the right ack message so i cans top retransmitting
-			if newErr == nil && dataReceived.BotId == myNewBot.Id && dataReceived.Message == myMessage {
-				break
+// sends message to bot once, returns nil only if bot answered with the ack of this message
+func deliver(bot Bot, sensor Sensor, redelivery bool) error {
 
-			} else if newErr, ok := newErr.(net.Error); ok && newErr.Timeout() {
-				continue
+	//blocking call : awaits for response to http request
+	response, err := newRequest(bot, sensor.Message, sensor, redelivery)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
 
-			} else {
-				time.Sleep(20 * time.Second)
-				continue
-			}
+	var dataReceived subResponse
+	if err := json.NewDecoder(response.Body).Decode(&dataReceived); err != nil {
+		return err
+	}
+
+	if dataReceived.MessageId != "" && dataReceived.MessageId != sensor.deliveredId() {
+		return fmt.Errorf("wrong ack from bot %s : %+v", bot.Id, dataReceived)
+	}
+	if dataReceived.BotId != bot.Id || dataReceived.MessageId == "" && dataReceived.Message != sensor.Message {
+		return fmt.Errorf("wrong ack from bot %s : %+v", bot.Id, dataReceived)
+	}
+	return nil
+}
+
+// http client used to notify bots, a bot which does not answer in time is retried as per retry policy
+var deliveryClient = &http.Client{Timeout: 10 * time.Second}
+
+// function which generates a new http request to notify  bot with message.
+// Bots can process every message once by discarding msg_id already seen, redelivery tells when that may happen
+// and seq orders messages of the broker
+func newRequest(bot Bot, message string, sensor Sensor, redelivery bool) (*http.Response, error) {
+
+	payload := deliveryPayload(bot, sensor, redelivery)
+	payload["msg"] = message
+
+	request, err := json.Marshal(payload)
+
+	if err != nil {
+		return nil, err
+	}
+
+	httpRequest, err := http.NewRequest("POST", bot.callbackURL(), bytes.NewBuffer(request))
+	if err != nil {
+		return nil, err
+	}
+	for header, value := range bot.CallbackHeaders {
+		httpRequest.Header.Set(header, value)
+	}
+	httpRequest.Header.Set("Content-Type", "application/json")
+
+	return deliveryClient.Do(httpRequest)
+}
+
+// body of the notification of sensor message to bot, with the structured payload of sensor if it has one
+func deliveryPayload(bot Bot, sensor Sensor, redelivery bool) map[string]interface{} {
+	payload := map[string]interface{}{
+		"msg":        sensor.Message,
+		"msg_id":     sensor.deliveredId(),
+		"seq":        sensor.Seq,
+		"redelivery": redelivery,
+		"botId":      bot.Id,
+		"bot_cs":     bot.CurrentSector,
+		"sensor":     sensor.Id,
+		"sensor_cs":  sensor.CurrentSector,
+		"topic":      sensor.Type,
+		"retained":   sensor.Retained,
+	}
+	if sensor.Offset != 0 {
+		payload["offset"] = sensor.Offset
+	}
+	if sensor.Payload != nil {
+		payload["payload"] = sensor.Payload
+	}
+	return payload
+}
+
+// checks structured payload of a sensor, if any
+func (payload *SensorPayload) validate() error {
+	if payload == nil {
+		return nil
+	}
+	switch payload.Value.(type) {
+	case nil, float64, string, bool:
+		return nil
+	}
+	return errors.New("payload value must be a number, a string or a boolean")
+}
+
+// returns every topic subscription of bot, topic of bots registered with a single one included
+func (bot Bot) topicSubscriptions() []Subscription {
+	subscriptions := append([]Subscription{}, bot.Subscriptions...)
+	if bot.Topic != "" && bot.subscriptionTo(bot.Topic) == nil {
+		subscriptions = append(subscriptions, Subscription{Topic: bot.Topic})
+	}
+	return subscriptions
+}
+
+// returns subscription of bot to topic, nil if bot is not subscribed to it
+func (bot Bot) subscriptionTo(topic string) *Subscription {
+	for k := range bot.Subscriptions {
+		if bot.Subscriptions[k].Topic == topic {
+			return &bot.Subscriptions[k]
 		}
+	}
+	return nil
+}
 
-	} else {
-		//got connection error
-		fmt.Println(err)
+// returns bot with subscription added, or replacing the one bot already had to the same topic
+func (bot Bot) withSubscription(subscription Subscription) Bot {
+	newBot := bot.withoutSubscription(subscription.Topic)
+	newBot.Subscriptions = append(newBot.Subscriptions, subscription)
+	return newBot
+}
 
+// returns bot without its subscription to topic
+func (bot Bot) withoutSubscription(topic string) Bot {
+	newBot := bot
+	newBot.Subscriptions = []Subscription{}
+	for _, subscription := range bot.Subscriptions {
+		if subscription.Topic != topic {
+			newBot.Subscriptions = append(newBot.Subscriptions, subscription)
+		}
+	}
+	if newBot.Topic == topic {
+		newBot.Topic = ""
 	}
-	wg.Done()
+	return newBot
 }
 
-// function which generates a new http request to notify  bot with message
-func newRequest(bot Bot, message string, sensor Sensor) *http.Response {
+// sectors where a context aware broker routes messages of subscription to bot: the ones subscription
+// is scoped to, or the current sector of bot, widened to the scope of subscription through warehouse layout
+func (subscription Subscription) sectorsOf(bot Bot) []string {
+	sectors := subscription.Sectors
+	if len(sectors) == 0 {
+		sectors = []string{bot.CurrentSector}
+	}
+	return warehouse.expand(sectors, subscription.Scope)
+}
 
-	request, err := json.Marshal(map[string]string{
-		"msg":       message,
-		"botId":     bot.Id,
-		"bot_cs":    bot.CurrentSector,
-		"sensor":    sensor.Id,
-		"sensor_cs": sensor.CurrentSector,
-		"topic":     bot.Topic,
-	})
+// returns the url where bot is notified, bots registered with ipaddr only listen on port 5001
+func (bot Bot) callbackURL() string {
+	if bot.CallbackURL != "" {
+		return bot.CallbackURL
+	}
+	return "http://" + bot.IpAddress + ":5001/"
+}
 
-	if err != nil {
-		fmt.Println(err)
+// checks topic filters of every subscription of bot
+func (bot Bot) validateSubscriptions() error {
+	for _, subscription := range bot.topicSubscriptions() {
+		if err := subscription.validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (subscription Subscription) validate() error {
+	if err := validateTopicFilter(subscription.Topic); err != nil {
+		return err
+	}
+	if !eb.matchesRegisteredTopic(subscription.Topic) {
+		return errors.New("topic " + subscription.Topic + " matches no registered topic")
+	}
+	if subscription.Routing != "" {
+		if err := validateRouting(subscription.Routing); err != nil {
+			return err
+		}
+	}
+	if subscription.Filter != "" {
+		if _, err := compileFilter(subscription.Filter); err != nil {
+			return err
+		}
+	}
+	return validateScope(subscription.Scope)
+}
+
+// checks callback url given by bot, if any
+func (bot Bot) validateCallback() error {
+	if bot.CallbackURL == "" {
 		return nil
 	}
 
-	resp, err := http.Post("http://"+bot.IpAddress+":5001/", "application/json", bytes.NewBuffer(request))
+	callback, err := url.Parse(bot.CallbackURL)
+	if err != nil {
+		return err
+	}
+	if callback.Scheme != "http" && callback.Scheme != "https" || callback.Host == "" {
+		return errors.New("callback_url must be an absolute http or https url")
+	}
+	return nil
+}
+
+// writes resilience entries for every bot to be notified, a broker without them could not recover after a crash
+func (eb *Broker) writeBotIdsAndMessage(bots []Bot, sensor Sensor) {
+	if err := eb.repo.WriteBotIdsAndMessage(bots, sensor); err != nil {
+		panic(err.Error())
+	}
+}
+
+func (eb *Broker) removeResilienceEntry(botId string, msgId string, sensor string) {
+	if err := eb.repo.RemoveResilienceEntry(botId, msgId, sensor); err != nil {
+		panic(err.Error())
+	}
+}
 
+func (eb *Broker) removePubRequest(sensorId string, msgId string) {
+	if err := eb.repo.RemovePubRequest(sensorId, msgId); err != nil {
+		panic(err.Error())
+	}
+}
+
+// tells if sensor message is already known by the broker, as a request not yet delivered to every push bot.
+// Message is looked up by key with the msg_id sensor kept from the ack, a message without it cannot be told
+// apart from a new reading with the same text, so it is never pending. A message left only to pull bots is
+// not pending anymore, its retransmission reaches them again with the same msg_id
+func (eb *Broker) IsPending(sensor Sensor) (bool, error) {
+
+	if sensor.MessageId == "" {
+		return false, nil
+	}
+
+	request, err := eb.repo.GetSensorRequest(sensor.Id, sensor.MessageId)
 	if err != nil {
-		fmt.Println(err)
-		return nil
+		return false, err
+	}
+	return request.Id != "", nil
+}
+
+// gives sensor message a new unique id and the next sequence number of the broker
+func (eb *Broker) AssignMessageId(sensor *Sensor) {
+	sensor.MessageId = shortuuid.New()
+	sensor.Seq = eb.nextSeq()
+}
+
+// returns a sequence number greater than every other one returned, also by previous runs of the broker
+// once LoadSeq has run: it is the current time in microseconds unless more than one number per microsecond
+// is needed, or the clock is behind the last number given
+func (eb *Broker) nextSeq() int64 {
+	for {
+		last := atomic.LoadInt64(&eb.lastSeq)
+		next := time.Now().UnixNano() / int64(time.Microsecond)
+		if next <= last {
+			next = last + 1
+		}
+		if atomic.CompareAndSwapInt64(&eb.lastSeq, last, next) {
+			return next
+		}
+	}
+}
+
+// makes nextSeq start after the greatest seq number and offset stored by previous runs of the broker,
+// among pending publish requests, topic logs and retained messages, so they stay unique after a restart
+// even if the clock went back
+func (eb *Broker) LoadSeq() error {
+	stored := []Sensor{}
+
+	requestSlice, err := eb.repo.GetRequestEntries()
+	if err != nil {
+		return err
+	}
+	stored = append(stored, requestSlice...)
+
+	for _, topic := range eb.Topics() {
+		entries, err := eb.repo.GetLastHistory(topic.Name, 1)
+		if err != nil {
+			return err
+		}
+		stored = append(stored, entries...)
 	}
-	return resp
+
+	retainedList, err := eb.repo.GetRetained()
+	if err != nil {
+		return err
+	}
+	stored = append(stored, retainedList...)
+
+	last := atomic.LoadInt64(&eb.lastSeq)
+	for _, sensor := range stored {
+		if sensor.Seq > last {
+			last = sensor.Seq
+		}
+		if sensor.Offset > last {
+			last = sensor.Offset
+		}
+	}
+	atomic.StoreInt64(&eb.lastSeq, last)
+	return nil
+}
+
+// queues a sensor publish request, blocking the caller while the queue is full
+func (eb *Broker) Enqueue(sensor Sensor) {
+	eb.sensorsRequest <- sensor
 }
 
-// init broker
-var eb = &Broker{
-	subscribers:    map[string]BotSlice{},
-	subscribersCtx: map[key]BotSlice{},
-	sensorsRequest: []Sensor{},
+// starts workers goroutines serving queued publish requests, an idle worker sleeps on the queue
+func (eb *Broker) StartPublishers(workers int) {
+	for i := 0; i < workers; i++ {
+		go func() {
+			for request := range eb.sensorsRequest {
+				eb.Publish(request)
+			}
+		}()
+	}
 }
+
+// creates a new broker which persists its state through repo and queues up to queueSize publish requests
+func NewBroker(repo Repository, queueSize int, maxInFlight int) *Broker {
+	return &Broker{
+		subscribers:    newTopicTree(),
+		subscribersCtx: map[string]*topicTree{},
+		sensorsRequest: make(chan Sensor, queueSize),
+		inFlight:       make(chan struct{}, maxInFlight),
+		repo:           repo,
+
+		retryPolicy:        defaultRetryPolicy,
+		topicRetryPolicies: map[string]RetryPolicy{},
+
+		routing:      globalRouting,
+		topicRouting: map[string]string{},
+
+		pullSignals: map[string]chan struct{}{},
+		pulled:      map[tableKey]*pullHandout{},
+
+		streams: map[string]*botStream{},
+
+		watchers: map[*watcher]bool{},
+
+		topics: map[string]registeredTopic{},
+
+		retained: map[retainedKey]Sensor{},
+
+		logLocks: map[string]*sync.Mutex{},
+	}
+}
+
+// init broker in main, once storage backend is selected
+var eb *Broker
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// starts a broker on a memory repository as main does, with no bots and retries which do not wait
+func newTestBroker(t *testing.T) {
+	eb = NewBroker(NewMemoryRepository(), 64, 64)
+	initTopics(true)
+	bots = nil
+	if err := eb.SetRetryPolicy("", immediateRetryPolicy); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// callback of a push bot, which passes on every notification and acks it once it has failed the first times
+type testCallback struct {
+	*httptest.Server
+	notified chan map[string]interface{}
+	failures int32
+}
+
+func newTestCallback(t *testing.T, failures int32) *testCallback {
+	callback := &testCallback{notified: make(chan map[string]interface{}, 64), failures: failures}
+	callback.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Error(err)
+		}
+		callback.notified <- payload
+
+		if atomic.AddInt32(&callback.failures, -1) >= 0 {
+			http.Error(w, "not now", http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(subResponse{BotId: payload["botId"].(string), MessageId: payload["msg_id"].(string)})
+	}))
+	t.Cleanup(callback.Close)
+	return callback
+}
+
+func (callback *testCallback) next(t *testing.T) map[string]interface{} {
+	select {
+	case payload := <-callback.notified:
+		return payload
+	case <-time.After(5 * time.Second):
+		t.Fatal("bot was not notified")
+		return nil
+	}
+}
+
+// stores sensor message as acceptSensor does and publishes it at once, without a worker
+func publishTestMessage(t *testing.T, sensor Sensor) Sensor {
+	eb.AssignMessageId(&sensor)
+	if err := eb.repo.AddSensorRequest(sensor); err != nil {
+		t.Fatal(err)
+	}
+	eb.Publish(sensor)
+	return sensor
+}
+
+// waits for every publish request to be removed, which happens once all its bots acked or gave up
+func waitRequestsRemoved(t *testing.T) {
+	for i := 0; i < 500; i++ {
+		if requestList, _ := eb.repo.GetRequestEntries(); len(requestList) == 0 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("publish requests were not removed")
+}
+
+func TestPublishAck(t *testing.T) {
+	newTestBroker(t)
+	callback := newTestCallback(t, 0)
+	if err := addBot(Bot{Id: "b1", Topic: "temperature", CallbackURL: callback.URL}); err != nil {
+		t.Fatal(err)
+	}
+	addBot(Bot{Id: "b2", Topic: "humidity", CallbackURL: callback.URL})
+
+	sensor := publishTestMessage(t, Sensor{Id: "s1", Type: "temperature", Message: "20"})
+
+	payload := callback.next(t)
+	if payload["botId"] != "b1" || payload["msg_id"] != sensor.MessageId || payload["msg"] != "20" || payload["redelivery"] != false {
+		t.Errorf("got notification %+v", payload)
+	}
+
+	waitRequestsRemoved(t)
+	if resilienceList, _ := eb.repo.GetResilienceEntries(); len(resilienceList) != 0 {
+		t.Errorf("acked message left resilience entries %+v", resilienceList)
+	}
+	if deadList, _ := eb.repo.GetDeadLetters(); len(deadList) != 0 {
+		t.Errorf("acked message left dead letters %+v", deadList)
+	}
+	select {
+	case payload := <-callback.notified:
+		t.Errorf("got notification %+v of a bot not subscribed", payload)
+	default:
+	}
+}
+
+func TestPublishRetriesUntilAck(t *testing.T) {
+	newTestBroker(t)
+	callback := newTestCallback(t, 2)
+	addBot(Bot{Id: "b1", Topic: "temperature", CallbackURL: callback.URL})
+
+	publishTestMessage(t, Sensor{Id: "s1", Type: "temperature", Message: "20"})
+
+	for attempt := 1; attempt <= 3; attempt++ {
+		// every attempt after the first may reach a bot which got the message already
+		if payload := callback.next(t); payload["redelivery"] != (attempt > 1) {
+			t.Errorf("attempt %d has redelivery %v", attempt, payload["redelivery"])
+		}
+	}
+
+	waitRequestsRemoved(t)
+	if resilienceList, _ := eb.repo.GetResilienceEntries(); len(resilienceList) != 0 {
+		t.Errorf("acked message left resilience entries %+v", resilienceList)
+	}
+	if deadList, _ := eb.repo.GetDeadLetters(); len(deadList) != 0 {
+		t.Errorf("acked message left dead letters %+v", deadList)
+	}
+}
+
+func TestPublishDeadLetters(t *testing.T) {
+	newTestBroker(t)
+	callback := newTestCallback(t, 1000)
+	addBot(Bot{Id: "b1", Topic: "temperature", CallbackURL: callback.URL})
+
+	sensor := publishTestMessage(t, Sensor{Id: "s1", Type: "temperature", Message: "20"})
+
+	waitRequestsRemoved(t)
+	deadList, _ := eb.repo.GetDeadLetters()
+	if len(deadList) != 1 || deadList[0].BotId != "b1" || deadList[0].Sensor.MessageId != sensor.MessageId {
+		t.Fatalf("got dead letters %+v", deadList)
+	}
+	if deadList[0].Attempts != immediateRetryPolicy.MaxAttempts || len(callback.notified) != immediateRetryPolicy.MaxAttempts {
+		t.Errorf("message went to dead letters after %d attempts, want %d", deadList[0].Attempts, immediateRetryPolicy.MaxAttempts)
+	}
+	if resilienceList, _ := eb.repo.GetResilienceEntries(); len(resilienceList) != 0 {
+		t.Errorf("dead letter left resilience entries %+v", resilienceList)
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+const (
+	pushMode = "push"
+	pullMode = "pull"
+)
+
+// longest time a pull request waits for a message
+const maxPullWait = 60 * time.Second
+
+// handouts of a message to a pull bot which has not acked it yet. Every handout is an attempt of the retry
+// policy of its topic, the message goes to dead letters once attempts or time since the first one run out
+type pullHandout struct {
+	attempts int
+	first    time.Time
+}
+
+// tells whether handout has used up the budget of policy at now
+func (handout *pullHandout) exhausted(policy RetryPolicy, now time.Time) bool {
+	if policy.MaxAttempts > 0 && handout.attempts >= policy.MaxAttempts {
+		return true
+	}
+	return policy.MaxElapsedMs > 0 && now.Sub(handout.first) > time.Duration(policy.MaxElapsedMs)*time.Millisecond
+}
+
+// returns the channel signalling bot has new messages, created on first use
+func (eb *Broker) pullSignal(botId string) chan struct{} {
+	eb.pullLock.Lock()
+	defer eb.pullLock.Unlock()
+
+	signal, found := eb.pullSignals[botId]
+	if !found {
+		signal = make(chan struct{}, 1)
+		eb.pullSignals[botId] = signal
+	}
+	return signal
+}
+
+// wakes up the long-poll of bot, if any, a bot not polling right now finds messages at next pull
+func (eb *Broker) wakePuller(botId string) {
+	select {
+	case eb.pullSignal(botId) <- struct{}{}:
+	default:
+	}
+}
+
+// returns at most max messages (0 means all) written for bot and not yet acked, waiting up to wait
+// for one to arrive if there is none. Waiting ends early if done is closed
+func (eb *Broker) Pull(bot Bot, max int, wait time.Duration, done <-chan struct{}) ([]map[string]interface{}, error) {
+
+	timeout := time.NewTimer(wait)
+	defer timeout.Stop()
+
+	for {
+		messages, err := eb.pendingMessages(bot, max)
+		if err != nil || len(messages) > 0 {
+			return messages, err
+		}
+
+		select {
+		case <-eb.pullSignal(bot.Id):
+		case <-timeout.C:
+			return messages, nil
+		case <-done:
+			return messages, nil
+		}
+	}
+}
+
+// builds the payloads of at most max (0 means all) bot resilience entries, in publish order.
+// Entries whose retry budget is used up go to dead letters instead
+func (eb *Broker) pendingMessages(bot Bot, max int) ([]map[string]interface{}, error) {
+
+	entries, err := eb.repo.GetBotResilienceEntries(bot.Id)
+	if err != nil {
+		return nil, err
+	}
+	sortBySeq(entries)
+
+	now := time.Now()
+	messages := []map[string]interface{}{}
+	exhausted := map[*pullHandout]resilienceEntry{}
+
+	eb.pullLock.Lock()
+	for _, entry := range entries {
+		if max > 0 && len(messages) == max {
+			break
+		}
+
+		pulledKey := tableKey{bot.Id, entry.MessageId}
+		handout, found := eb.pulled[pulledKey]
+		if !found {
+			handout = &pullHandout{first: now}
+			eb.pulled[pulledKey] = handout
+		}
+		if handout.exhausted(eb.retryPolicyFor(entry.Sensor.Type), now) {
+			delete(eb.pulled, pulledKey)
+			exhausted[handout] = entry
+			continue
+		}
+
+		handout.attempts++
+		messages = append(messages, deliveryPayload(bot, entry.Sensor, handout.attempts > 1))
+	}
+	eb.pullLock.Unlock()
+
+	for handout, entry := range exhausted {
+		eb.deadLetter(bot, entry.Sensor, handout.attempts, errors.New("bot "+bot.Id+" did not ack the message it pulled"))
+	}
+	return messages, nil
+}
+
+// removes resilience entries of messages acked by pull bot, unknown ids are ignored
+func (eb *Broker) AckPulled(bot Bot, msgIds []string) error {
+
+	resilience, err := eb.repo.GetBotResilienceEntries(bot.Id)
+	if err != nil {
+		return err
+	}
+
+	acked := map[string]bool{}
+	for _, msgId := range msgIds {
+		acked[msgId] = true
+	}
+
+	for _, resilienceItem := range resilience {
+		if !acked[resilienceItem.Sensor.deliveredId()] {
+			continue
+		}
+		if err := eb.repo.RemoveResilienceEntry(bot.Id, resilienceItem.MessageId, resilienceItem.Sensor.Id); err != nil {
+			return err
+		}
+
+		eb.pullLock.Lock()
+		delete(eb.pulled, tableKey{bot.Id, resilienceItem.MessageId})
+		eb.pullLock.Unlock()
+	}
+	return nil
+}
+
+// messages found at startup may have been handed out by the previous run of the broker
+func (eb *Broker) markPulled(resilience []resilienceEntry) {
+	eb.pullLock.Lock()
+	defer eb.pullLock.Unlock()
+
+	for _, resilienceItem := range resilience {
+		eb.pulled[tableKey{resilienceItem.BotId, resilienceItem.MessageId}] = &pullHandout{attempts: 1, first: time.Now()}
+	}
+}
+
+// forgets long-poll signal and handouts of bot, once it is removed or not pulling anymore
+func (eb *Broker) forgetPuller(botId string) {
+	eb.pullLock.Lock()
+	defer eb.pullLock.Unlock()
+
+	delete(eb.pullSignals, botId)
+	for pulledKey := range eb.pulled {
+		if pulledKey.Id == botId {
+			delete(eb.pulled, pulledKey)
+		}
+	}
+}
+
+// hands entries, the messages bot pulled without acking them, over to bot which does not pull anymore,
+// as copies flagged as redelivery, delivered one at a time in publish order
+func (eb *Broker) handOverPulled(bot Bot, entries []resilienceEntry) error {
+	sortBySeq(entries)
+
+	var delivered <-chan struct{}
+	for _, entry := range entries {
+		var err error
+		if delivered, err = eb.publishTo([]Bot{bot}, entry.Sensor.copy(), true, delivered); err != nil {
+			return err
+		}
+		if err := eb.repo.RemoveResilienceEntry(bot.Id, entry.MessageId, entry.Sensor.Id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sortBySeq(entries []resilienceEntry) {
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Sensor.Seq < entries[j].Sensor.Seq
+	})
+}
@@ -0,0 +1,98 @@
+package main
+
+import "strings"
+
+const defaultDataDir = "wbmq-data"
+
+// tables every Repository manages
+var repositoryTables = []string{"bots", "sensorsRequest", "resilience", "deadLetters", "topics", "history", "retained", "settings"}
+
+// Setting is a broker setting changed at runtime, stored in settings table so that it survives a restart.
+// Value is the setting encoded in json
+type Setting struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Repository is the persistence layer used by the broker: it stores subscribed bots (bots table),
+// pending sensor publish requests (sensorsRequest table) and the per bot messages still awaiting
+// an ack (resilience table), so that the broker can recover its state after a crash.
+// Messages which bots never acked are kept in deadLetters table, registered topics in topics table
+// and the log of messages of every topic in history table. The last message of every topic and sector
+// is kept in retained table, and broker settings changed at runtime in settings table
+type Repository interface {
+	// returns the number of tables already present in the backend
+	ExistingTables() (int, error)
+	// creates bots, sensorsRequest, resilience, deadLetters, topics, history, retained and settings tables
+	CreateTables() error
+
+	AddBot(bot Bot) error
+	GetBots() ([]Bot, error)
+	RemoveBot(id string) error
+
+	AddSensorRequest(sensor Sensor) error
+	GetRequestEntries() ([]Sensor, error)
+	// returns an empty Sensor if (sensorId, msgId) is not found
+	GetSensorRequest(sensorId string, msgId string) (Sensor, error)
+	RemovePubRequest(sensorId string, msgId string) error
+
+	// adds an entry (botId + sensorId, msgId) for every bot that has to be notified with sensor message
+	WriteBotIdsAndMessage(bots []Bot, sensor Sensor) error
+	GetResilienceEntries() ([]resilienceEntry, error)
+	// returns resilience entries of bot only, without reading the whole table
+	GetBotResilienceEntries(botId string) ([]resilienceEntry, error)
+	RemoveResilienceEntry(botId string, msgId string, sensor string) error
+
+	AddDeadLetter(letter DeadLetter) error
+	GetDeadLetters() ([]DeadLetter, error)
+	// returns an empty DeadLetter if id is not found
+	GetDeadLetter(id string) (DeadLetter, error)
+	RemoveDeadLetter(id string) error
+
+	AddTopic(topic Topic) error
+	GetTopics() ([]Topic, error)
+	RemoveTopic(name string) error
+
+	// history is the log of every topic, keyed by (type, offset)
+	AddHistoryEntry(sensor Sensor) error
+	// returns messages of topic with fromOffset <= offset <= toOffset, sorted by offset, the first limit ones if limit is not 0
+	GetHistory(topic string, fromOffset int64, toOffset int64, limit int) ([]Sensor, error)
+	// returns the last n messages of topic, sorted by offset
+	GetLastHistory(topic string, n int) ([]Sensor, error)
+	// removes messages of topic with offset <= untilOffset
+	RemoveHistory(topic string, untilOffset int64) error
+
+	// retained is keyed by (type, current_sector)
+	AddRetained(sensor Sensor) error
+	GetRetained() ([]Sensor, error)
+	RemoveRetained(topic string, sector string) error
+
+	// settings is keyed by name
+	AddSetting(setting Setting) error
+	GetSettings() ([]Setting, error)
+	RemoveSetting(name string) error
+}
+
+// returns the Repository implementation selected by name at startup,
+// file backend accepts the data directory as "file:<dir>" (defaultDataDir if missing)
+func newRepository(name string) Repository {
+	backend := strings.SplitN(name, ":", 2)
+	switch backend[0] {
+	case "dynamo":
+		return NewDynamoDBRepository()
+	case "memory":
+		return NewMemoryRepository()
+	case "file":
+		dir := defaultDataDir
+		if len(backend) > 1 && backend[1] != "" {
+			dir = backend[1]
+		}
+		repo, err := NewFileRepository(dir)
+		if err != nil {
+			panic(err)
+		}
+		return repo
+	default:
+		panic("Unknown storage backend : " + name)
+	}
+}
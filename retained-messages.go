@@ -0,0 +1,99 @@
+package main
+
+import (
+	"sort"
+)
+
+// the last message published on every topic from every sector is retained, so that a bot subscribing
+// gets at once the last known reading of the topics and sectors it is subscribed to instead of waiting
+// for the next one. Messages without a sector are not retained
+
+// key of a retained message
+type retainedKey struct {
+	topic  string
+	sector string
+}
+
+// loads retained messages from storage
+func (eb *Broker) LoadRetained() error {
+	retainedList, err := eb.repo.GetRetained()
+	if err != nil {
+		return err
+	}
+
+	eb.retainedLock.Lock()
+	defer eb.retainedLock.Unlock()
+
+	for _, sensor := range retainedList {
+		eb.retained[retainedKey{sensor.Type, sensor.CurrentSector}] = sensor
+	}
+	return nil
+}
+
+// retains sensor message as the last one of its topic and sector, unless a later one is already retained
+func (eb *Broker) setRetained(sensor Sensor) error {
+	if sensor.CurrentSector == "" {
+		return nil
+	}
+	key := retainedKey{sensor.Type, sensor.CurrentSector}
+
+	eb.retainedLock.Lock()
+	defer eb.retainedLock.Unlock()
+
+	if last, found := eb.retained[key]; found && last.Seq > sensor.Seq {
+		return nil
+	}
+	if err := eb.repo.AddRetained(sensor); err != nil {
+		return err
+	}
+	eb.retained[key] = sensor
+	return nil
+}
+
+// forgets retained messages of topic
+func (eb *Broker) removeRetained(topic string) error {
+	eb.retainedLock.Lock()
+	defer eb.retainedLock.Unlock()
+
+	for key := range eb.retained {
+		if key.topic != topic {
+			continue
+		}
+		if err := eb.repo.RemoveRetained(key.topic, key.sector); err != nil {
+			return err
+		}
+		delete(eb.retained, key)
+	}
+	return nil
+}
+
+// returns retained messages routed to newBot which were not routed to oldBot, oldest first
+func (eb *Broker) retainedFor(oldBot Bot, newBot Bot) []Sensor {
+	eb.retainedLock.RLock()
+	defer eb.retainedLock.RUnlock()
+
+	retainedList := []Sensor{}
+	for _, sensor := range eb.retained {
+		if eb.routedTo(newBot, sensor) && !eb.routedTo(oldBot, sensor) {
+			retainedList = append(retainedList, sensor)
+		}
+	}
+	sort.Slice(retainedList, func(i, j int) bool { return retainedList[i].Seq < retainedList[j].Seq })
+	return retainedList
+}
+
+// sends to bot retainedList, the retained messages retainedFor found for it. Every message is a copy for
+// bot only, keeping its msg_id and flagged as retained, delivered and acked like any other one, oldest first
+func (eb *Broker) deliverRetained(bot Bot, retainedList []Sensor) error {
+	var delivered <-chan struct{}
+	for _, sensor := range retainedList {
+		sensor = sensor.copy()
+		sensor.Retained = true
+
+		var err error
+		if delivered, err = eb.publishTo([]Bot{bot}, sensor, false, delivered); err != nil {
+			return err
+		}
+	}
+	return nil
+}
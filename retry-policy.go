@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// RetryPolicy tells how a message is retransmitted to a bot which does not ack it:
+// waits grow exponentially from InitialBackoff up to MaxBackoff, each one randomized by +/- Jitter,
+// until either MaxAttempts deliveries were tried or MaxElapsed time has passed (0 means no limit)
+type RetryPolicy struct {
+	MaxAttempts      int     `json:"max_attempts"`
+	InitialBackoffMs int64   `json:"initial_backoff_ms"`
+	MaxBackoffMs     int64   `json:"max_backoff_ms"`
+	Multiplier       float64 `json:"multiplier"`
+	Jitter           float64 `json:"jitter"`
+	MaxElapsedMs     int64   `json:"max_elapsed_ms"`
+}
+
+// settings table stores the broker retry policy with this name, and the one of every topic under it
+const retryPolicyPrefix = "retryPolicy"
+
+// policy used by a broker when no other one is configured
+var defaultRetryPolicy = RetryPolicy{
+	MaxAttempts:      10,
+	InitialBackoffMs: 1000,
+	MaxBackoffMs:     20000,
+	Multiplier:       2,
+	Jitter:           0.2,
+	MaxElapsedMs:     10 * 60 * 1000,
+}
+
+func (policy RetryPolicy) Validate() error {
+	if policy.MaxAttempts < 0 || policy.MaxElapsedMs < 0 {
+		return errors.New("max_attempts and max_elapsed_ms can not be negative")
+	}
+	if policy.MaxAttempts == 0 && policy.MaxElapsedMs == 0 {
+		return errors.New("at least one of max_attempts and max_elapsed_ms is needed")
+	}
+	if policy.InitialBackoffMs < 0 || policy.MaxBackoffMs < policy.InitialBackoffMs {
+		return errors.New("backoff must satisfy 0 <= initial_backoff_ms <= max_backoff_ms")
+	}
+	if policy.Multiplier < 1 {
+		return errors.New("multiplier must be at least 1")
+	}
+	if policy.Jitter < 0 || policy.Jitter > 1 {
+		return errors.New("jitter must be between 0 and 1")
+	}
+	return nil
+}
+
+// returns the wait before delivery attempt number attempt+1
+func (policy RetryPolicy) backoff(attempt int) time.Duration {
+	wait := float64(policy.InitialBackoffMs) * math.Pow(policy.Multiplier, float64(attempt-1))
+	if wait > float64(policy.MaxBackoffMs) {
+		wait = float64(policy.MaxBackoffMs)
+	}
+	wait = wait * (1 - policy.Jitter + 2*policy.Jitter*rand.Float64())
+	return time.Duration(wait) * time.Millisecond
+}
+
+// calls deliver until it succeeds or the policy budget is exhausted,
+// returns the number of attempts done and the last error (nil if delivered)
+func (policy RetryPolicy) Do(deliver func() error) (int, error) {
+
+	start := time.Now()
+	maxElapsed := time.Duration(policy.MaxElapsedMs) * time.Millisecond
+
+	for attempt := 1; ; attempt++ {
+
+		err := deliver()
+		if err == nil {
+			return attempt, nil
+		}
+
+		if policy.MaxAttempts > 0 && attempt >= policy.MaxAttempts {
+			return attempt, err
+		}
+
+		wait := policy.backoff(attempt)
+		if maxElapsed > 0 && time.Since(start)+wait > maxElapsed {
+			return attempt, err
+		}
+		time.Sleep(wait)
+	}
+}
+
+// returns the retry policy for messages published on topic
+func (eb *Broker) retryPolicyFor(topic string) RetryPolicy {
+	eb.policyLock.RLock()
+	defer eb.policyLock.RUnlock()
+
+	if policy, found := eb.topicRetryPolicies[topic]; found {
+		return policy
+	}
+	return eb.retryPolicy
+}
+
+// name of the setting storing the broker retry policy, or the one of topic if not empty
+func retryPolicySetting(topic string) string {
+	if topic == "" {
+		return retryPolicyPrefix
+	}
+	return retryPolicyPrefix + "/" + topic
+}
+
+// sets the broker retry policy, or the one of topic if not empty. Policy is stored before it is used,
+// so it is still the one in place after a restart
+func (eb *Broker) SetRetryPolicy(topic string, policy RetryPolicy) error {
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+
+	eb.policyLock.Lock()
+	defer eb.policyLock.Unlock()
+
+	value, err := json.Marshal(policy)
+	if err != nil {
+		return err
+	}
+	if err := eb.repo.AddSetting(Setting{Name: retryPolicySetting(topic), Value: string(value)}); err != nil {
+		return err
+	}
+
+	if topic == "" {
+		eb.retryPolicy = policy
+	} else {
+		eb.topicRetryPolicies[topic] = policy
+	}
+	return nil
+}
+
+// removes the retry policy of topic, which goes back to the broker one
+func (eb *Broker) RemoveRetryPolicy(topic string) error {
+	eb.policyLock.Lock()
+	defer eb.policyLock.Unlock()
+
+	if err := eb.repo.RemoveSetting(retryPolicySetting(topic)); err != nil {
+		return err
+	}
+	delete(eb.topicRetryPolicies, topic)
+	return nil
+}
+
+// loads retry policies set before the last restart
+func (eb *Broker) LoadRetryPolicies() error {
+	settingList, err := eb.repo.GetSettings()
+	if err != nil {
+		return err
+	}
+
+	eb.policyLock.Lock()
+	defer eb.policyLock.Unlock()
+
+	for _, setting := range settingList {
+		if setting.Name != retryPolicyPrefix && !strings.HasPrefix(setting.Name, retryPolicyPrefix+"/") {
+			continue
+		}
+		var policy RetryPolicy
+		if err := json.Unmarshal([]byte(setting.Value), &policy); err != nil {
+			return err
+		}
+		if setting.Name == retryPolicyPrefix {
+			eb.retryPolicy = policy
+		} else {
+			eb.topicRetryPolicies[strings.TrimPrefix(setting.Name, retryPolicyPrefix+"/")] = policy
+		}
+	}
+	return nil
+}
+
+// returns the broker retry policy and a copy of the per topic ones
+func (eb *Broker) RetryPolicies() (RetryPolicy, map[string]RetryPolicy) {
+	eb.policyLock.RLock()
+	defer eb.policyLock.RUnlock()
+
+	topicPolicies := map[string]RetryPolicy{}
+	for topic, policy := range eb.topicRetryPolicies {
+		topicPolicies[topic] = policy
+	}
+	return eb.retryPolicy, topicPolicies
+}
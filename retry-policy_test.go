@@ -0,0 +1,133 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// policy retrying at once, so tests do not wait
+var immediateRetryPolicy = RetryPolicy{MaxAttempts: 5, Multiplier: 1}
+
+func TestRetryPolicyDoSucceeds(t *testing.T) {
+	calls := 0
+	attempts, err := immediateRetryPolicy.Do(func() error {
+		calls++
+		if calls < 3 {
+			return errors.New("no ack")
+		}
+		return nil
+	})
+
+	if err != nil {
+		t.Fatalf("got error %v, want delivery at third attempt", err)
+	}
+	if attempts != 3 || calls != 3 {
+		t.Errorf("got %d attempts and %d calls, want 3", attempts, calls)
+	}
+}
+
+func TestRetryPolicyDoExhaustsAttempts(t *testing.T) {
+	calls := 0
+	attempts, err := immediateRetryPolicy.Do(func() error {
+		calls++
+		return fmt.Errorf("no ack %d", calls)
+	})
+
+	if err == nil || err.Error() != "no ack 5" {
+		t.Fatalf("got error %v, want the last one", err)
+	}
+	if attempts != immediateRetryPolicy.MaxAttempts || calls != immediateRetryPolicy.MaxAttempts {
+		t.Errorf("got %d attempts and %d calls, want %d", attempts, calls, immediateRetryPolicy.MaxAttempts)
+	}
+}
+
+func TestRetryPolicyDoStopsAtMaxElapsed(t *testing.T) {
+	policy := RetryPolicy{InitialBackoffMs: 20, MaxBackoffMs: 20, Multiplier: 1, MaxElapsedMs: 50}
+
+	start := time.Now()
+	attempts, err := policy.Do(func() error {
+		return errors.New("no ack")
+	})
+
+	if err == nil {
+		t.Fatal("got no error from a delivery always failing")
+	}
+	// attempts at 0, 20 and 40 ms, a fourth one would be past max elapsed time
+	if attempts != 3 {
+		t.Errorf("got %d attempts, want 3", attempts)
+	}
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond+20*time.Millisecond {
+		t.Errorf("retries went on for %v, past max elapsed time", elapsed)
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{InitialBackoffMs: 100, MaxBackoffMs: 1000, Multiplier: 2}
+
+	for attempt, want := range map[int]time.Duration{
+		1: 100 * time.Millisecond,
+		2: 200 * time.Millisecond,
+		4: 800 * time.Millisecond,
+		5: 1000 * time.Millisecond,
+		9: 1000 * time.Millisecond,
+	} {
+		if wait := policy.backoff(attempt); wait != want {
+			t.Errorf("backoff after attempt %d is %v, want %v", attempt, wait, want)
+		}
+	}
+
+	policy.Jitter = 0.5
+	for k := 0; k < 100; k++ {
+		if wait := policy.backoff(1); wait < 50*time.Millisecond || wait > 150*time.Millisecond {
+			t.Fatalf("backoff %v is not within jitter of 100ms", wait)
+		}
+	}
+}
+
+func TestRetryPolicyValidate(t *testing.T) {
+	if err := defaultRetryPolicy.Validate(); err != nil {
+		t.Errorf("default policy is not valid : %v", err)
+	}
+
+	for name, policy := range map[string]RetryPolicy{
+		"no limit":             {InitialBackoffMs: 10, MaxBackoffMs: 10, Multiplier: 1},
+		"negative attempts":    {MaxAttempts: -1, MaxElapsedMs: 10, Multiplier: 1},
+		"backoff out of order": {MaxAttempts: 3, InitialBackoffMs: 20, MaxBackoffMs: 10, Multiplier: 1},
+		"multiplier below 1":   {MaxAttempts: 3, Multiplier: 0.5},
+		"jitter above 1":       {MaxAttempts: 3, Multiplier: 1, Jitter: 1.5},
+	} {
+		if err := policy.Validate(); err == nil {
+			t.Errorf("policy with %s is valid", name)
+		}
+	}
+}
+
+func TestRetryPolicyPersisted(t *testing.T) {
+	repo := NewMemoryRepository()
+	broker := NewBroker(repo, 1, 1)
+
+	topicPolicy := RetryPolicy{MaxAttempts: 3, InitialBackoffMs: 10, MaxBackoffMs: 10, Multiplier: 1}
+	if err := broker.SetRetryPolicy("", immediateRetryPolicy); err != nil {
+		t.Fatal(err)
+	}
+	if err := broker.SetRetryPolicy("temperature", topicPolicy); err != nil {
+		t.Fatal(err)
+	}
+	broker.SetRetryPolicy("humidity", topicPolicy)
+	broker.RemoveRetryPolicy("humidity")
+
+	// a broker restarted on the same repository finds the policies in place before
+	restarted := NewBroker(repo, 1, 1)
+	if err := restarted.LoadRetryPolicies(); err != nil {
+		t.Fatal(err)
+	}
+	brokerPolicy, topicPolicies := restarted.RetryPolicies()
+	if brokerPolicy != immediateRetryPolicy {
+		t.Errorf("broker policy is %+v after restart", brokerPolicy)
+	}
+	if len(topicPolicies) != 1 || topicPolicies["temperature"] != topicPolicy {
+		t.Errorf("topic policies are %+v after restart", topicPolicies)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// routing modes of a subscription: a "global" one gets messages from every sector (or from the sectors it is
+// scoped to), a "sector" one only the messages from the sectors where the bot is, as a context aware broker does
+const (
+	globalRouting = "global"
+	sectorRouting = "sector"
+)
+
+// settings table stores the broker routing mode with this name, and the one of every topic under it
+const routingPrefix = "routing"
+
+func validateRouting(mode string) error {
+	if mode != globalRouting && mode != sectorRouting {
+		return errors.New("routing mode must be " + globalRouting + " or " + sectorRouting)
+	}
+	return nil
+}
+
+// returns the routing mode of topic, the broker one unless topic has its own
+func (eb *Broker) routingFor(topic string) string {
+	eb.routingLock.RLock()
+	defer eb.routingLock.RUnlock()
+
+	if mode, found := eb.topicRouting[topic]; found {
+		return mode
+	}
+	return eb.routing
+}
+
+// returns the routing mode of subscription for messages of topic
+func (eb *Broker) routingOf(subscription Subscription, topic string) string {
+	if subscription.Routing != "" {
+		return subscription.Routing
+	}
+	return eb.routingFor(topic)
+}
+
+// name of the setting storing the broker routing mode, or the one of topic if not empty
+func routingSetting(topic string) string {
+	if topic == "" {
+		return routingPrefix
+	}
+	return routingPrefix + "/" + topic
+}
+
+// sets the broker routing mode, or the one of topic if not empty. Both indexes of subscribers
+// are always up to date, so messages published from now on are routed with the new mode.
+// Mode is stored before it is used, so it is still the one in place after a restart
+func (eb *Broker) SetRouting(topic string, mode string) error {
+	if err := validateRouting(mode); err != nil {
+		return err
+	}
+
+	eb.routingLock.Lock()
+	defer eb.routingLock.Unlock()
+
+	value, err := json.Marshal(mode)
+	if err != nil {
+		return err
+	}
+	if err := eb.repo.AddSetting(Setting{Name: routingSetting(topic), Value: string(value)}); err != nil {
+		return err
+	}
+
+	if topic == "" {
+		eb.routing = mode
+	} else {
+		eb.topicRouting[topic] = mode
+	}
+	return nil
+}
+
+// removes the routing mode of topic, which goes back to the broker one
+func (eb *Broker) RemoveRouting(topic string) error {
+	eb.routingLock.Lock()
+	defer eb.routingLock.Unlock()
+
+	if err := eb.repo.RemoveSetting(routingSetting(topic)); err != nil {
+		return err
+	}
+	delete(eb.topicRouting, topic)
+	return nil
+}
+
+// loads routing modes set before the last restart, a stored broker mode replaces the one chosen at startup
+func (eb *Broker) LoadRouting() error {
+	settingList, err := eb.repo.GetSettings()
+	if err != nil {
+		return err
+	}
+
+	eb.routingLock.Lock()
+	defer eb.routingLock.Unlock()
+
+	for _, setting := range settingList {
+		if setting.Name != routingPrefix && !strings.HasPrefix(setting.Name, routingPrefix+"/") {
+			continue
+		}
+		var mode string
+		if err := json.Unmarshal([]byte(setting.Value), &mode); err != nil {
+			return err
+		}
+		if setting.Name == routingPrefix {
+			eb.routing = mode
+		} else {
+			eb.topicRouting[strings.TrimPrefix(setting.Name, routingPrefix+"/")] = mode
+		}
+	}
+	return nil
+}
+
+// returns the broker routing mode and a copy of the per topic ones
+func (eb *Broker) RoutingModes() (string, map[string]string) {
+	eb.routingLock.RLock()
+	defer eb.routingLock.RUnlock()
+
+	topicModes := map[string]string{}
+	for topic, mode := range eb.topicRouting {
+		topicModes[topic] = mode
+	}
+	return eb.routing, topicModes
+}
@@ -0,0 +1,37 @@
+package main
+
+import "testing"
+
+func TestRoutingPersisted(t *testing.T) {
+	repo := NewMemoryRepository()
+	broker := NewBroker(repo, 1, 1)
+
+	if err := broker.SetRouting("", sectorRouting); err != nil {
+		t.Fatal(err)
+	}
+	if err := broker.SetRouting("temperature", globalRouting); err != nil {
+		t.Fatal(err)
+	}
+	broker.SetRouting("humidity", sectorRouting)
+	broker.RemoveRouting("humidity")
+
+	if err := broker.SetRouting("motion", "nearest"); err == nil {
+		t.Error("unknown routing mode was set")
+	}
+
+	// a broker restarted on the same repository finds the modes in place before
+	restarted := NewBroker(repo, 1, 1)
+	if err := restarted.LoadRouting(); err != nil {
+		t.Fatal(err)
+	}
+	brokerMode, topicModes := restarted.RoutingModes()
+	if brokerMode != sectorRouting {
+		t.Errorf("broker routing is %s after restart", brokerMode)
+	}
+	if len(topicModes) != 1 || topicModes["temperature"] != globalRouting {
+		t.Errorf("topic routing modes are %+v after restart", topicModes)
+	}
+	if restarted.routingFor("humidity") != sectorRouting {
+		t.Errorf("humidity is routed %s, want the broker mode", restarted.routingFor("humidity"))
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// messages a watcher can lag behind before new ones are dropped for it
+const watcherBuffer = 256
+
+// read-only listener of published messages, optionally restricted to a topic filter and a sector
+type watcher struct {
+	topic    string
+	sector   string
+	messages chan Sensor
+}
+
+func (eb *Broker) addWatcher(topic string, sector string) *watcher {
+	myWatcher := &watcher{
+		topic:    topic,
+		sector:   sector,
+		messages: make(chan Sensor, watcherBuffer),
+	}
+
+	eb.watchLock.Lock()
+	eb.watchers[myWatcher] = true
+	eb.watchLock.Unlock()
+	return myWatcher
+}
+
+func (eb *Broker) removeWatcher(myWatcher *watcher) {
+	eb.watchLock.Lock()
+	delete(eb.watchers, myWatcher)
+	eb.watchLock.Unlock()
+}
+
+// hands sensor message to every watcher interested in it, never blocking the publisher:
+// a watcher too slow to keep up loses messages
+func (eb *Broker) notifyWatchers(sensor Sensor) {
+	eb.watchLock.RLock()
+	defer eb.watchLock.RUnlock()
+
+	for myWatcher := range eb.watchers {
+		if myWatcher.topic != "" && !topicMatches(myWatcher.topic, sensor.Type) {
+			continue
+		}
+		if myWatcher.sector != "" && myWatcher.sector != sensor.CurrentSector {
+			continue
+		}
+		select {
+		case myWatcher.messages <- sensor:
+		default:
+		}
+	}
+}
+
+// streams as Server-Sent Events every published message, filtered by "topic" and "sector" query parameters.
+// Topic filter is checked as bot subscriptions are, a dashboard would otherwise wait forever on a typo
+func streamMessages(w http.ResponseWriter, r *http.Request) {
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	topic := r.URL.Query().Get("topic")
+	if topic != "" {
+		if err := validateTopicFilter(topic); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !eb.matchesRegisteredTopic(topic) {
+			http.Error(w, "topic "+topic+" matches no registered topic", http.StatusBadRequest)
+			return
+		}
+	}
+
+	myWatcher := eb.addWatcher(topic, r.URL.Query().Get("sector"))
+	defer eb.removeWatcher(myWatcher)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	flusher.Flush()
+
+	for {
+		select {
+		case sensor := <-myWatcher.messages:
+			event := map[string]interface{}{
+				"msg":       sensor.Message,
+				"msg_id":    sensor.MessageId,
+				"seq":       sensor.Seq,
+				"sensor":    sensor.Id,
+				"sensor_cs": sensor.CurrentSector,
+				"topic":     sensor.Type,
+			}
+			if sensor.Payload != nil {
+				event["payload"] = sensor.Payload
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", sensor.MessageId, sensor.Type, data)
+			flusher.Flush()
+
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/lithammer/shortuuid"
+	"sync"
+	"time"
+)
+
+// bots connected through a websocket, a gRPC stream or MQTT get their messages on the open connection
+// and ack them in-band
+const (
+	websocketMode = "websocket"
+	grpcMode      = "grpc"
+)
+
+const streamAckTimeout = 10 * time.Second
+
+// open connection of a bot, with the acks its deliveries are waiting for
+type botStream struct {
+	send   func(payload map[string]interface{}) error
+	forget func(msgId string) // optional, drops what send keeps about a delivery once it is over
+
+	acks     map[string]chan struct{}
+	acksLock sync.Mutex
+	closed   chan struct{}
+}
+
+func newBotStream(send func(payload map[string]interface{}) error) *botStream {
+	return &botStream{
+		send:   send,
+		acks:   map[string]chan struct{}{},
+		closed: make(chan struct{}),
+	}
+}
+
+// registers the channel signalled when bot acks msgId
+func (stream *botStream) waitAck(msgId string) chan struct{} {
+	stream.acksLock.Lock()
+	defer stream.acksLock.Unlock()
+
+	ack := make(chan struct{}, 1)
+	stream.acks[msgId] = ack
+	return ack
+}
+
+func (stream *botStream) forgetAck(msgId string) {
+	stream.acksLock.Lock()
+	delete(stream.acks, msgId)
+	stream.acksLock.Unlock()
+
+	if stream.forget != nil {
+		stream.forget(msgId)
+	}
+}
+
+func (stream *botStream) signalAck(msgId string) {
+	stream.acksLock.Lock()
+	defer stream.acksLock.Unlock()
+
+	if ack, found := stream.acks[msgId]; found {
+		ack <- struct{}{}
+		delete(stream.acks, msgId)
+	}
+}
+
+// sends message to bot on its open connection and awaits for the in-band ack,
+// a bot which is not connected right now is retried as per retry policy
+func (eb *Broker) deliverStream(bot Bot, sensor Sensor, redelivery bool) error {
+
+	eb.streamLock.RLock()
+	stream, found := eb.streams[bot.Id]
+	eb.streamLock.RUnlock()
+
+	if !found {
+		return errors.New("bot " + bot.Id + " is not connected")
+	}
+
+	ack := stream.waitAck(sensor.deliveredId())
+	defer stream.forgetAck(sensor.deliveredId())
+
+	if err := stream.send(deliveryPayload(bot, sensor, redelivery)); err != nil {
+		return err
+	}
+
+	select {
+	case <-ack:
+		return nil
+	case <-stream.closed:
+		return errors.New("connection of bot " + bot.Id + " closed")
+	case <-time.After(streamAckTimeout):
+		return errors.New("no ack from bot " + bot.Id)
+	}
+}
+
+// subscribes bot in mode and routes its deliveries to stream. A reconnecting bot is moved to the
+// subscriptions and sector it registers with, it keeps its own if it registers with none
+func (eb *Broker) registerStream(bot Bot, mode string, stream *botStream) (Bot, error) {
+	myBot, backlog, err := eb.storeStreamBot(bot, mode, stream)
+	if err != nil {
+		return myBot, err
+	}
+
+	//stream is there before the backlog is sent, so its messages find it
+	if err := backlog.send(); err != nil {
+		eb.unregisterStream(myBot.Id, stream)
+		return myBot, err
+	}
+
+	fmt.Println("---- Bot " + myBot.Id + " connected through " + mode)
+	return myBot, nil
+}
+
+// stores bot as registerStream does, returning the messages to send to it
+func (eb *Broker) storeStreamBot(bot Bot, mode string, stream *botStream) (Bot, botBacklog, error) {
+	//bot is looked up and added at once, so two connections of the same new bot do not both add it
+	botsLock.Lock()
+	defer botsLock.Unlock()
+
+	var backlog botBacklog
+	myBot := botById(bot.Id)
+	if myBot.Id == "" {
+
+		myBot = bot
+		if myBot.Id == "" {
+			myBot.Id = shortuuid.New()
+		}
+		myBot.Mode = mode
+
+		if err := myBot.validateSubscriptions(); err != nil {
+			return myBot, backlog, err
+		}
+
+		//stream is there before bot is subscribed, so messages published meanwhile find it
+		eb.streamLock.Lock()
+		eb.streams[myBot.Id] = stream
+		eb.streamLock.Unlock()
+
+		var err error
+		if backlog, err = storeNewBot(myBot); err != nil {
+			eb.unregisterStream(myBot.Id, stream)
+			return myBot, backlog, err
+		}
+
+	} else if myBot.Mode != mode {
+		return myBot, backlog, errors.New("bot " + myBot.Id + " is already registered without " + mode)
+
+	} else {
+
+		newBot := myBot
+		if bot.Topic != "" || len(bot.Subscriptions) > 0 {
+			newBot.Topic = bot.Topic
+			newBot.Subscriptions = bot.Subscriptions
+		}
+		if bot.CurrentSector != "" {
+			newBot.CurrentSector = bot.CurrentSector
+		}
+		if err := newBot.validateSubscriptions(); err != nil {
+			return myBot, backlog, err
+		}
+
+		eb.streamLock.Lock()
+		eb.streams[myBot.Id] = stream
+		eb.streamLock.Unlock()
+
+		var err error
+		if backlog, err = updateBot(myBot, newBot); err != nil {
+			eb.unregisterStream(myBot.Id, stream)
+			return myBot, backlog, err
+		}
+		myBot = newBot
+	}
+	return myBot, backlog, nil
+}
+
+// forgets connection of bot, unless bot has already reconnected on a new one
+func (eb *Broker) unregisterStream(botId string, stream *botStream) {
+	eb.streamLock.Lock()
+	if eb.streams[botId] == stream {
+		delete(eb.streams, botId)
+	}
+	eb.streamLock.Unlock()
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Topic is a topic sensors can publish on and bots can subscribe to. A topic with a schema only
+// accepts messages whose payload is valid against it, e.g. {"type": "object", "required": ["value"]},
+// a topic with a retention keeps the messages of its log for it instead of the default retention
+type Topic struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description,omitempty"`
+	Schema      json.RawMessage  `json:"schema,omitempty"`    // json schema of payload
+	Retention   *RetentionPolicy `json:"retention,omitempty"` // defaultRetentionPolicy if missing
+}
+
+// topics registered when a new registry is created
+var defaultTopics = []string{"temperature", "humidity", "motion"}
+
+// topic of the registry, schema is nil if topic has none
+type registeredTopic struct {
+	topic  Topic
+	schema *jsonschema.Schema
+}
+
+// checks topic and compiles its schema, if any
+func (topic Topic) compile() (*jsonschema.Schema, error) {
+	if err := validateTopicFilter(topic.Name); err != nil {
+		return nil, err
+	}
+	if strings.ContainsAny(topic.Name, singleLevelWildcard+multiLevelWildcard) {
+		return nil, errors.New("topic " + topic.Name + " : wildcards are only allowed in subscriptions")
+	}
+	if err := topic.Retention.validate(); err != nil {
+		return nil, err
+	}
+	if len(topic.Schema) == 0 || string(topic.Schema) == "null" {
+		return nil, nil
+	}
+
+	compiler := jsonschema.NewCompiler()
+	if err := compiler.AddResource("schema.json", bytes.NewReader(topic.Schema)); err != nil {
+		return nil, errors.New("topic " + topic.Name + " : " + err.Error())
+	}
+	schema, err := compiler.Compile("schema.json")
+	if err != nil {
+		return nil, errors.New("topic " + topic.Name + " : " + err.Error())
+	}
+	return schema, nil
+}
+
+// loads registered topics from storage
+func (eb *Broker) LoadTopics() error {
+	topicList, err := eb.repo.GetTopics()
+	if err != nil {
+		return err
+	}
+
+	eb.topicLock.Lock()
+	defer eb.topicLock.Unlock()
+
+	for _, topic := range topicList {
+		schema, err := topic.compile()
+		if err != nil {
+			return err
+		}
+		eb.topics[topic.Name] = registeredTopic{topic: topic, schema: schema}
+	}
+	return nil
+}
+
+// registers topic, tells whether it was created or a topic with the same name already exists
+func (eb *Broker) RegisterTopic(topic Topic) (bool, error) {
+	schema, err := topic.compile()
+	if err != nil {
+		return false, err
+	}
+
+	eb.topicLock.Lock()
+	defer eb.topicLock.Unlock()
+
+	if _, found := eb.topics[topic.Name]; found {
+		return false, nil
+	}
+	if err := eb.repo.AddTopic(topic); err != nil {
+		return false, err
+	}
+	eb.topics[topic.Name] = registeredTopic{topic: topic, schema: schema}
+	return true, nil
+}
+
+// replaces description, schema and retention of a registered topic, tells whether it was registered
+func (eb *Broker) UpdateTopic(topic Topic) (bool, error) {
+	schema, err := topic.compile()
+	if err != nil {
+		return false, err
+	}
+
+	eb.topicLock.Lock()
+	defer eb.topicLock.Unlock()
+
+	if _, found := eb.topics[topic.Name]; !found {
+		return false, nil
+	}
+	if err := eb.repo.AddTopic(topic); err != nil {
+		return false, err
+	}
+	eb.topics[topic.Name] = registeredTopic{topic: topic, schema: schema}
+	return true, nil
+}
+
+// removes topic, its log and its retained messages from the registry, tells whether it was registered.
+// Bots keep their subscriptions to it, but sensors cannot publish on it anymore
+func (eb *Broker) RemoveTopic(name string) (bool, error) {
+	eb.topicLock.Lock()
+	defer eb.topicLock.Unlock()
+
+	if _, found := eb.topics[name]; !found {
+		return false, nil
+	}
+	if err := eb.repo.RemoveTopic(name); err != nil {
+		return false, err
+	}
+	delete(eb.topics, name)
+	if err := eb.repo.RemoveHistory(name, math.MaxInt64); err != nil {
+		return true, err
+	}
+	return true, eb.removeRetained(name)
+}
+
+// returns registered topic with name, if any
+func (eb *Broker) GetTopic(name string) (Topic, bool) {
+	eb.topicLock.RLock()
+	defer eb.topicLock.RUnlock()
+
+	registered, found := eb.topics[name]
+	return registered.topic, found
+}
+
+// returns registered topics sorted by name
+func (eb *Broker) Topics() []Topic {
+	eb.topicLock.RLock()
+	defer eb.topicLock.RUnlock()
+
+	topicList := []Topic{}
+	for _, registered := range eb.topics {
+		topicList = append(topicList, registered.topic)
+	}
+	sort.Slice(topicList, func(i, j int) bool { return topicList[i].Name < topicList[j].Name })
+	return topicList
+}
+
+// tells if subscription topic filter matches at least one registered topic
+func (eb *Broker) matchesRegisteredTopic(filter string) bool {
+	eb.topicLock.RLock()
+	defer eb.topicLock.RUnlock()
+
+	for name := range eb.topics {
+		if topicMatches(filter, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// checks sensor publishes on a registered topic, with a payload valid against the topic schema
+func (eb *Broker) checkTopicOf(sensor Sensor) error {
+	if sensor.Type == "" {
+		return errors.New("type is missing")
+	}
+
+	eb.topicLock.RLock()
+	registered, found := eb.topics[sensor.Type]
+	eb.topicLock.RUnlock()
+
+	if !found {
+		return errors.New("topic " + sensor.Type + " is not registered")
+	}
+	if registered.schema == nil {
+		return nil
+	}
+	if sensor.Payload == nil {
+		return errors.New("topic " + sensor.Type + " requires a payload")
+	}
+
+	// payload goes through json, so that it is validated as the document a client would send
+	data, err := json.Marshal(sensor.Payload)
+	if err != nil {
+		return err
+	}
+	var document interface{}
+	if err := json.Unmarshal(data, &document); err != nil {
+		return err
+	}
+	if err := registered.schema.Validate(document); err != nil {
+		return errors.New("payload is not valid for topic " + sensor.Type + " : " + err.Error())
+	}
+	return nil
+}
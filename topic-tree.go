@@ -0,0 +1,131 @@
+package main
+
+import (
+	"errors"
+	"strings"
+)
+
+// topics are hierarchical, with levels separated by "/" (e.g. env/temperature/ambient). A subscription topic
+// may use "+" for exactly one level and "#", as last level, for any number of levels, zero included
+const (
+	topicSeparator      = "/"
+	singleLevelWildcard = "+"
+	multiLevelWildcard  = "#"
+)
+
+// node of a topicTree, bots are the subscribers whose topic filter ends here
+type topicNode struct {
+	children map[string]*topicNode
+	bots     BotSlice
+}
+
+// topicTree indexes subscribers by topic filter one level at a time, so a publish only visits
+// the levels of its topic and the wildcards met along them, however many subscriptions there are
+type topicTree struct {
+	root *topicNode
+}
+
+func newTopicNode() *topicNode {
+	return &topicNode{children: map[string]*topicNode{}}
+}
+
+func newTopicTree() *topicTree {
+	return &topicTree{root: newTopicNode()}
+}
+
+// adds bot to subscribers of filter
+func (tree *topicTree) add(filter string, bot Bot) {
+	node := tree.root
+	for _, level := range strings.Split(filter, topicSeparator) {
+		child, found := node.children[level]
+		if !found {
+			child = newTopicNode()
+			node.children[level] = child
+		}
+		node = child
+	}
+	node.bots = append(node.bots, bot)
+}
+
+// removes bot with botId from subscribers of filter, dropping the nodes left without subscribers
+func (tree *topicTree) remove(filter string, botId string) {
+	tree.root.remove(strings.Split(filter, topicSeparator), botId)
+}
+
+// tells if node is left empty after removing bot from levels below it
+func (node *topicNode) remove(levels []string, botId string) bool {
+	if len(levels) == 0 {
+		node.bots = withoutBot(node.bots, botId)
+	} else if child, found := node.children[levels[0]]; found {
+		if child.remove(levels[1:], botId) {
+			delete(node.children, levels[0])
+		}
+	}
+	return len(node.bots) == 0 && len(node.children) == 0
+}
+
+// returns every bot subscribed to a filter matching topic, once even if more of its filters match
+func (tree *topicTree) match(topic string) BotSlice {
+	matching := BotSlice{}
+	seen := map[string]bool{}
+	tree.root.match(strings.Split(topic, topicSeparator), func(bots BotSlice) {
+		for _, bot := range bots {
+			if !seen[bot.Id] {
+				seen[bot.Id] = true
+				matching = append(matching, bot)
+			}
+		}
+	})
+	return matching
+}
+
+func (node *topicNode) match(levels []string, found func(BotSlice)) {
+	// "#" matches the remaining levels, none included
+	if child, ok := node.children[multiLevelWildcard]; ok {
+		found(child.bots)
+	}
+	if len(levels) == 0 {
+		found(node.bots)
+		return
+	}
+	if child, ok := node.children[levels[0]]; ok {
+		child.match(levels[1:], found)
+	}
+	if child, ok := node.children[singleLevelWildcard]; ok {
+		child.match(levels[1:], found)
+	}
+}
+
+// tells if topic of a message matches filter of a subscription
+func topicMatches(filter string, topic string) bool {
+	filterLevels := strings.Split(filter, topicSeparator)
+	topicLevels := strings.Split(topic, topicSeparator)
+
+	for k, level := range filterLevels {
+		if level == multiLevelWildcard {
+			return true
+		}
+		if k == len(topicLevels) || level != singleLevelWildcard && level != topicLevels[k] {
+			return false
+		}
+	}
+	return len(filterLevels) == len(topicLevels)
+}
+
+// checks topic filter of a subscription: wildcards take a whole level and "#" can only be the last one
+func validateTopicFilter(filter string) error {
+	if filter == "" {
+		return errors.New("topic is missing")
+	}
+
+	levels := strings.Split(filter, topicSeparator)
+	for k, level := range levels {
+		if level == multiLevelWildcard && k < len(levels)-1 {
+			return errors.New("topic " + filter + " : " + multiLevelWildcard + " must be the last level")
+		}
+		if level != singleLevelWildcard && level != multiLevelWildcard && strings.ContainsAny(level, "+#") {
+			return errors.New("topic " + filter + " : wildcards must take a whole level")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"sort"
+	"testing"
+)
+
+// returns sorted ids of bots
+func botIds(bots BotSlice) []string {
+	ids := []string{}
+	for _, bot := range bots {
+		ids = append(ids, bot.Id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func sameIds(got []string, want []string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for k := range got {
+		if got[k] != want[k] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestTopicTreeMatch(t *testing.T) {
+	tree := newTopicTree()
+	tree.add("env/temperature/ambient", Bot{Id: "exact"})
+	tree.add("env/+/ambient", Bot{Id: "single"})
+	tree.add("env/#", Bot{Id: "multi"})
+	tree.add("#", Bot{Id: "all"})
+	tree.add("env/temperature", Bot{Id: "parent"})
+
+	for topic, want := range map[string][]string{
+		"env/temperature/ambient": {"all", "exact", "multi", "single"},
+		"env/humidity/ambient":    {"all", "multi", "single"},
+		"env/temperature":         {"all", "multi", "parent"},
+		"env":                     {"all", "multi"},
+		"motion":                  {"all"},
+		"env/temperature/probe":   {"all", "multi"},
+	} {
+		if got := botIds(tree.match(topic)); !sameIds(got, want) {
+			t.Errorf("bots matching %s are %v, want %v", topic, got, want)
+		}
+	}
+}
+
+func TestTopicTreeMatchesBotOnce(t *testing.T) {
+	tree := newTopicTree()
+	tree.add("env/temperature", Bot{Id: "b1"})
+	tree.add("env/+", Bot{Id: "b1"})
+	tree.add("env/#", Bot{Id: "b1"})
+
+	if got := botIds(tree.match("env/temperature")); !sameIds(got, []string{"b1"}) {
+		t.Errorf("bots matching env/temperature are %v, want b1 once", got)
+	}
+}
+
+func TestTopicTreeRemove(t *testing.T) {
+	tree := newTopicTree()
+	tree.add("env/temperature", Bot{Id: "b1"})
+	tree.add("env/temperature", Bot{Id: "b2"})
+	tree.add("env/+", Bot{Id: "b1"})
+
+	tree.remove("env/temperature", "b1")
+	if got := botIds(tree.match("env/temperature")); !sameIds(got, []string{"b1", "b2"}) {
+		t.Errorf("bots matching env/temperature are %v, b1 should still match through env/+", got)
+	}
+
+	tree.remove("env/+", "b1")
+	tree.remove("env/temperature", "b2")
+	if got := tree.match("env/temperature"); len(got) != 0 {
+		t.Errorf("bots matching env/temperature are %v after removing every one", botIds(got))
+	}
+	if len(tree.root.children) != 0 {
+		t.Errorf("nodes left without subscribers were not dropped : %v", tree.root.children)
+	}
+}
+
+func TestTopicMatches(t *testing.T) {
+	for _, test := range []struct {
+		filter string
+		topic  string
+		want   bool
+	}{
+		{"temperature", "temperature", true},
+		{"temperature", "humidity", false},
+		{"env/+/ambient", "env/temperature/ambient", true},
+		{"env/+/ambient", "env/temperature/probe", false},
+		{"env/+", "env", false},
+		{"env/#", "env", true},
+		{"env/#", "env/temperature/ambient", true},
+		{"#", "motion", true},
+		{"env/temperature", "env/temperature/ambient", false},
+		{"env/temperature/ambient", "env/temperature", false},
+	} {
+		if got := topicMatches(test.filter, test.topic); got != test.want {
+			t.Errorf("topicMatches(%q, %q) = %v, want %v", test.filter, test.topic, got, test.want)
+		}
+	}
+}
+
+func TestValidateTopicFilter(t *testing.T) {
+	for _, filter := range []string{"temperature", "env/+/ambient", "env/#", "#", "+"} {
+		if err := validateTopicFilter(filter); err != nil {
+			t.Errorf("filter %s is not valid : %v", filter, err)
+		}
+	}
+	for _, filter := range []string{"", "env/#/ambient", "env/temp+", "env/#x"} {
+		if err := validateTopicFilter(filter); err == nil {
+			t.Errorf("filter %q is valid", filter)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+)
+
+// scopes of a subscription around its sectors, the sectors themselves if missing
+const (
+	sectorScope   = "sector"
+	adjacentScope = "adjacent" // sectors and the ones adjacent to them
+	aisleScope    = "aisle"    // every sector in the aisles of sectors
+	zoneScope     = "zone"     // every sector in the zones of sectors
+)
+
+// WarehouseLayout describes zones of the warehouse, split in aisles of sectors, and which sectors are adjacent
+type WarehouseLayout struct {
+	Zones     []Zone     `json:"zones"`
+	Adjacency [][]string `json:"adjacency"` // pairs of adjacent sectors
+
+	zoneOf       map[string]string // sector --> zone
+	aisleOf      map[string]string // sector --> aisle
+	zoneSectors  map[string][]string
+	aisleSectors map[string][]string
+	neighbours   map[string][]string
+}
+
+// Zone
+type Zone struct {
+	Id     string  `json:"id"`
+	Aisles []Aisle `json:"aisles"`
+}
+
+// Aisle
+type Aisle struct {
+	Id      string   `json:"id"`
+	Sectors []string `json:"sectors"`
+}
+
+// layout in use, an empty one knows no sector so every scope is the sector itself
+var warehouse = &WarehouseLayout{}
+
+// reads layout from a json file
+func loadLayout(fileName string) (*WarehouseLayout, error) {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	var layout WarehouseLayout
+	if err := json.Unmarshal(data, &layout); err != nil {
+		return nil, err
+	}
+	if err := layout.index(); err != nil {
+		return nil, err
+	}
+	return &layout, nil
+}
+
+// builds lookups of layout, checking every sector belongs to one aisle only
+func (layout *WarehouseLayout) index() error {
+	layout.zoneOf = map[string]string{}
+	layout.aisleOf = map[string]string{}
+	layout.zoneSectors = map[string][]string{}
+	layout.aisleSectors = map[string][]string{}
+	layout.neighbours = map[string][]string{}
+
+	for _, zone := range layout.Zones {
+		for _, aisle := range zone.Aisles {
+			if _, found := layout.aisleSectors[aisle.Id]; found {
+				return errors.New("aisle " + aisle.Id + " is in the layout more than once")
+			}
+			layout.aisleSectors[aisle.Id] = aisle.Sectors
+
+			for _, sector := range aisle.Sectors {
+				if _, found := layout.zoneOf[sector]; found {
+					return errors.New("sector " + sector + " is in the layout more than once")
+				}
+				layout.zoneOf[sector] = zone.Id
+				layout.aisleOf[sector] = aisle.Id
+				layout.zoneSectors[zone.Id] = append(layout.zoneSectors[zone.Id], sector)
+			}
+		}
+	}
+
+	for _, pair := range layout.Adjacency {
+		if len(pair) != 2 {
+			return errors.New("adjacency must be made of sector pairs")
+		}
+		layout.neighbours[pair[0]] = append(layout.neighbours[pair[0]], pair[1])
+		layout.neighbours[pair[1]] = append(layout.neighbours[pair[1]], pair[0])
+	}
+	return nil
+}
+
+// returns sectors widened to scope, every sector once. A sector unknown to the layout is only itself
+func (layout *WarehouseLayout) expand(sectors []string, scope string) []string {
+	expanded := []string{}
+	seen := map[string]bool{}
+	add := func(sectors ...string) {
+		for _, sector := range sectors {
+			if !seen[sector] {
+				seen[sector] = true
+				expanded = append(expanded, sector)
+			}
+		}
+	}
+
+	for _, sector := range sectors {
+		add(sector)
+		switch scope {
+		case adjacentScope:
+			add(layout.neighbours[sector]...)
+		case aisleScope:
+			if aisle, found := layout.aisleOf[sector]; found {
+				add(layout.aisleSectors[aisle]...)
+			}
+		case zoneScope:
+			if zone, found := layout.zoneOf[sector]; found {
+				add(layout.zoneSectors[zone]...)
+			}
+		}
+	}
+	return expanded
+}
+
+// checks scope of a subscription
+func validateScope(scope string) error {
+	switch scope {
+	case "", sectorScope, adjacentScope, aisleScope, zoneScope:
+		return nil
+	}
+	return errors.New("scope must be " + sectorScope + ", " + adjacentScope + ", " + aisleScope + " or " + zoneScope)
+}
+
+// returns warehouse layout in use
+func getLayout(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(warehouse)
+}
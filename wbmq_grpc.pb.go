@@ -0,0 +1,345 @@
+// gRPC API of the broker, same operations of the REST handlers in http-api.go.
+// Go code is generated in package main with:
+//   protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative wbmq.proto
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             v4.25.3
+// source: wbmq.proto
+
+package main
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	WBMQ_SpawnBot_FullMethodName       = "/wbmq.WBMQ/SpawnBot"
+	WBMQ_UnsubscribeBot_FullMethodName = "/wbmq.WBMQ/UnsubscribeBot"
+	WBMQ_PublishSensor_FullMethodName  = "/wbmq.WBMQ/PublishSensor"
+	WBMQ_Status_FullMethodName         = "/wbmq.WBMQ/Status"
+	WBMQ_Stats_FullMethodName          = "/wbmq.WBMQ/Stats"
+	WBMQ_Subscribe_FullMethodName      = "/wbmq.WBMQ/Subscribe"
+)
+
+// WBMQClient is the client API for WBMQ service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type WBMQClient interface {
+	// same as POST /bot
+	SpawnBot(ctx context.Context, in *BotSpec, opts ...grpc.CallOption) (*BotSpec, error)
+	// same as POST /unsubscribeBot
+	UnsubscribeBot(ctx context.Context, in *BotSpec, opts ...grpc.CallOption) (*BotSpec, error)
+	// same as POST /sensor, the reply carries the ack in msg
+	PublishSensor(ctx context.Context, in *SensorRequest, opts ...grpc.CallOption) (*SensorRequest, error)
+	// same as GET /status
+	Status(ctx context.Context, in *StatusRequest, opts ...grpc.CallOption) (*StatusReply, error)
+	// same as GET /stats
+	Stats(ctx context.Context, in *StatsRequest, opts ...grpc.CallOption) (*StatsReply, error)
+	// registers a bot with the first request, then delivers its messages on the stream:
+	// every delivery must be acked with its msg_id
+	Subscribe(ctx context.Context, opts ...grpc.CallOption) (WBMQ_SubscribeClient, error)
+}
+
+type wBMQClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewWBMQClient(cc grpc.ClientConnInterface) WBMQClient {
+	return &wBMQClient{cc}
+}
+
+func (c *wBMQClient) SpawnBot(ctx context.Context, in *BotSpec, opts ...grpc.CallOption) (*BotSpec, error) {
+	out := new(BotSpec)
+	err := c.cc.Invoke(ctx, WBMQ_SpawnBot_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *wBMQClient) UnsubscribeBot(ctx context.Context, in *BotSpec, opts ...grpc.CallOption) (*BotSpec, error) {
+	out := new(BotSpec)
+	err := c.cc.Invoke(ctx, WBMQ_UnsubscribeBot_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *wBMQClient) PublishSensor(ctx context.Context, in *SensorRequest, opts ...grpc.CallOption) (*SensorRequest, error) {
+	out := new(SensorRequest)
+	err := c.cc.Invoke(ctx, WBMQ_PublishSensor_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *wBMQClient) Status(ctx context.Context, in *StatusRequest, opts ...grpc.CallOption) (*StatusReply, error) {
+	out := new(StatusReply)
+	err := c.cc.Invoke(ctx, WBMQ_Status_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *wBMQClient) Stats(ctx context.Context, in *StatsRequest, opts ...grpc.CallOption) (*StatsReply, error) {
+	out := new(StatsReply)
+	err := c.cc.Invoke(ctx, WBMQ_Stats_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *wBMQClient) Subscribe(ctx context.Context, opts ...grpc.CallOption) (WBMQ_SubscribeClient, error) {
+	stream, err := c.cc.NewStream(ctx, &WBMQ_ServiceDesc.Streams[0], WBMQ_Subscribe_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &wBMQSubscribeClient{stream}
+	return x, nil
+}
+
+type WBMQ_SubscribeClient interface {
+	Send(*SubscribeRequest) error
+	Recv() (*SubscribeEvent, error)
+	grpc.ClientStream
+}
+
+type wBMQSubscribeClient struct {
+	grpc.ClientStream
+}
+
+func (x *wBMQSubscribeClient) Send(m *SubscribeRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *wBMQSubscribeClient) Recv() (*SubscribeEvent, error) {
+	m := new(SubscribeEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// WBMQServer is the server API for WBMQ service.
+// All implementations must embed UnimplementedWBMQServer
+// for forward compatibility
+type WBMQServer interface {
+	// same as POST /bot
+	SpawnBot(context.Context, *BotSpec) (*BotSpec, error)
+	// same as POST /unsubscribeBot
+	UnsubscribeBot(context.Context, *BotSpec) (*BotSpec, error)
+	// same as POST /sensor, the reply carries the ack in msg
+	PublishSensor(context.Context, *SensorRequest) (*SensorRequest, error)
+	// same as GET /status
+	Status(context.Context, *StatusRequest) (*StatusReply, error)
+	// same as GET /stats
+	Stats(context.Context, *StatsRequest) (*StatsReply, error)
+	// registers a bot with the first request, then delivers its messages on the stream:
+	// every delivery must be acked with its msg_id
+	Subscribe(WBMQ_SubscribeServer) error
+	mustEmbedUnimplementedWBMQServer()
+}
+
+// UnimplementedWBMQServer must be embedded to have forward compatible implementations.
+type UnimplementedWBMQServer struct {
+}
+
+func (UnimplementedWBMQServer) SpawnBot(context.Context, *BotSpec) (*BotSpec, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SpawnBot not implemented")
+}
+func (UnimplementedWBMQServer) UnsubscribeBot(context.Context, *BotSpec) (*BotSpec, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UnsubscribeBot not implemented")
+}
+func (UnimplementedWBMQServer) PublishSensor(context.Context, *SensorRequest) (*SensorRequest, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PublishSensor not implemented")
+}
+func (UnimplementedWBMQServer) Status(context.Context, *StatusRequest) (*StatusReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Status not implemented")
+}
+func (UnimplementedWBMQServer) Stats(context.Context, *StatsRequest) (*StatsReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Stats not implemented")
+}
+func (UnimplementedWBMQServer) Subscribe(WBMQ_SubscribeServer) error {
+	return status.Errorf(codes.Unimplemented, "method Subscribe not implemented")
+}
+func (UnimplementedWBMQServer) mustEmbedUnimplementedWBMQServer() {}
+
+// UnsafeWBMQServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to WBMQServer will
+// result in compilation errors.
+type UnsafeWBMQServer interface {
+	mustEmbedUnimplementedWBMQServer()
+}
+
+func RegisterWBMQServer(s grpc.ServiceRegistrar, srv WBMQServer) {
+	s.RegisterService(&WBMQ_ServiceDesc, srv)
+}
+
+func _WBMQ_SpawnBot_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BotSpec)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WBMQServer).SpawnBot(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WBMQ_SpawnBot_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WBMQServer).SpawnBot(ctx, req.(*BotSpec))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WBMQ_UnsubscribeBot_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BotSpec)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WBMQServer).UnsubscribeBot(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WBMQ_UnsubscribeBot_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WBMQServer).UnsubscribeBot(ctx, req.(*BotSpec))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WBMQ_PublishSensor_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SensorRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WBMQServer).PublishSensor(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WBMQ_PublishSensor_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WBMQServer).PublishSensor(ctx, req.(*SensorRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WBMQ_Status_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WBMQServer).Status(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WBMQ_Status_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WBMQServer).Status(ctx, req.(*StatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WBMQ_Stats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WBMQServer).Stats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WBMQ_Stats_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WBMQServer).Stats(ctx, req.(*StatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WBMQ_Subscribe_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(WBMQServer).Subscribe(&wBMQSubscribeServer{stream})
+}
+
+type WBMQ_SubscribeServer interface {
+	Send(*SubscribeEvent) error
+	Recv() (*SubscribeRequest, error)
+	grpc.ServerStream
+}
+
+type wBMQSubscribeServer struct {
+	grpc.ServerStream
+}
+
+func (x *wBMQSubscribeServer) Send(m *SubscribeEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *wBMQSubscribeServer) Recv() (*SubscribeRequest, error) {
+	m := new(SubscribeRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// WBMQ_ServiceDesc is the grpc.ServiceDesc for WBMQ service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var WBMQ_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "wbmq.WBMQ",
+	HandlerType: (*WBMQServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "SpawnBot",
+			Handler:    _WBMQ_SpawnBot_Handler,
+		},
+		{
+			MethodName: "UnsubscribeBot",
+			Handler:    _WBMQ_UnsubscribeBot_Handler,
+		},
+		{
+			MethodName: "PublishSensor",
+			Handler:    _WBMQ_PublishSensor_Handler,
+		},
+		{
+			MethodName: "Status",
+			Handler:    _WBMQ_Status_Handler,
+		},
+		{
+			MethodName: "Stats",
+			Handler:    _WBMQ_Stats_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Subscribe",
+			Handler:       _WBMQ_Subscribe_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "wbmq.proto",
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"github.com/gorilla/websocket"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	websocketPingInterval = 30 * time.Second
+	websocketReadTimeout  = 2 * websocketPingInterval
+)
+
+// frame exchanged on a bot websocket: the bot sends "register" with its Bot subscription and "ack" for
+// every message, the broker answers "registered" and sends "message" frames with the delivery payload
+type websocketFrame struct {
+	Type      string                 `json:"type"`
+	Bot       *Bot                   `json:"bot,omitempty"`
+	MessageId string                 `json:"msg_id,omitempty"`
+	Payload   map[string]interface{} `json:"payload,omitempty"`
+	Error     string                 `json:"error,omitempty"`
+}
+
+// websocket of a bot, gorilla connections support one concurrent writer only
+type websocketConn struct {
+	conn      *websocket.Conn
+	writeLock sync.Mutex
+}
+
+var upgrader = websocket.Upgrader{
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+func (wsConn *websocketConn) write(frame websocketFrame) error {
+	wsConn.writeLock.Lock()
+	defer wsConn.writeLock.Unlock()
+
+	wsConn.conn.SetWriteDeadline(time.Now().Add(streamAckTimeout))
+	return wsConn.conn.WriteJSON(frame)
+}
+
+// upgrades request to a websocket on which a bot registers its subscription and receives messages
+func websocketSubscribe(w http.ResponseWriter, r *http.Request) {
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// upgrader already answered with an error
+		return
+	}
+	defer conn.Close()
+
+	wsConn := &websocketConn{conn: conn}
+	stream := newBotStream(func(payload map[string]interface{}) error {
+		return wsConn.write(websocketFrame{Type: "message", Payload: payload})
+	})
+	defer close(stream.closed)
+
+	conn.SetReadDeadline(time.Now().Add(websocketReadTimeout))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(websocketReadTimeout))
+	})
+
+	//first frame must be the registration of the bot
+	var register websocketFrame
+	if err := conn.ReadJSON(&register); err != nil {
+		return
+	}
+	if register.Type != "register" || register.Bot == nil {
+		wsConn.write(websocketFrame{Type: "error", Error: "first frame must register a bot"})
+		return
+	}
+
+	myBot, err := eb.registerStream(*register.Bot, websocketMode, stream)
+	if err != nil {
+		wsConn.write(websocketFrame{Type: "error", Error: err.Error()})
+		return
+	}
+	defer eb.unregisterStream(myBot.Id, stream)
+
+	registered := myBot.redacted()
+	if err := wsConn.write(websocketFrame{Type: "registered", Bot: &registered}); err != nil {
+		return
+	}
+
+	go keepAlive(wsConn, stream.closed)
+
+	for {
+		var frame websocketFrame
+		if err := conn.ReadJSON(&frame); err != nil {
+			return
+		}
+		conn.SetReadDeadline(time.Now().Add(websocketReadTimeout))
+
+		if frame.Type == "ack" {
+			stream.signalAck(frame.MessageId)
+		}
+	}
+}
+
+// pings bot until connection is closed, so that a dead bot is found out by the read deadline
+func keepAlive(wsConn *websocketConn, closed chan struct{}) {
+	ticker := time.NewTicker(websocketPingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			wsConn.writeLock.Lock()
+			err := wsConn.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(streamAckTimeout))
+			wsConn.writeLock.Unlock()
+			if err != nil {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}